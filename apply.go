@@ -0,0 +1,53 @@
+package smap
+
+import (
+	"errors"
+	"sort"
+)
+
+// Apply hydrates each raw override value into the exported field of dst
+// named by its key, regardless of tags. Keys are applied in sorted order;
+// every failure, including a key naming no exported field (ErrFieldNotFound),
+// is collected and returned joined, each as a MergeFieldError naming the key
+// in FieldName (see FieldErrors). Fields with valid overrides are set even when others fail.
+func Apply(dst interface{}, overrides map[string]string, opts ...Option) error {
+	return NewMerger(opts...).Apply(dst, overrides)
+}
+
+// Apply hydrates raw override values into the named fields of dst. See the
+// package-level Apply for details.
+func (m *Merger) Apply(dst interface{}, overrides map[string]string) error {
+	dstVal, err := makeDstValue(dst)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	tag := &sTag{}
+	for _, name := range names {
+		field, ok := m.cfg.dstField(dstVal.Type(), name)
+		if !ok || field.PkgPath != "" {
+			errs = append(errs, NewFieldNameError(ErrFieldNotFound, name, "", "string"))
+			continue
+		}
+		fieldVal, err := dstVal.FieldByIndexErr(field.Index)
+		if err != nil {
+			errs = append(errs, NewFieldNameError(ErrFieldNotFound, name, field.Type.String(), "string"))
+			continue // Promoted through a nil embedded pointer
+		}
+		hydrated, err := hydratedElement(m.cfg, tag, field.Type, overrides[name])
+		if err != nil {
+			err = hydrateInputError(m.cfg, overrides[name], err)
+			errs = append(errs, NewFieldNameError(err, name, field.Type.String(), "string"))
+			continue
+		}
+		fieldVal.Set(hydrated)
+	}
+	return errors.Join(errs...)
+}
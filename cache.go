@@ -0,0 +1,142 @@
+package smap
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Cache holds the parsed smap tags of destination struct types so that they
+// are parsed once rather than on every merge. A Cache may be shared by any
+// number of Mergers (see WithCache) and is safe for concurrent use. Entries
+// are keyed by type, tag keys, and tag splitter, so Mergers with differing
+// options do not collide.
+type Cache struct {
+	mu    sync.RWMutex
+	plans map[cacheKey][]fieldPlan
+}
+
+// NewCache constructs an empty Cache.
+func NewCache() *Cache {
+	return &Cache{
+		plans: make(map[cacheKey][]fieldPlan),
+	}
+}
+
+// Reset removes all entries from the cache.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.plans = make(map[cacheKey][]fieldPlan)
+}
+
+// defaultCache is the process-wide Cache returned by DefaultCache.
+var defaultCache = NewCache()
+
+// DefaultCache returns the process-wide Cache that PreloadTypes fills. Mergers
+// use it only when given WithCache(DefaultCache()).
+func DefaultCache() *Cache {
+	return defaultCache
+}
+
+// PreloadTypes parses and caches the smap tags of the destination struct types
+// of the given example values in DefaultCache, under the default tag key and
+// splitter, so that latency-sensitive services pay the reflection cost up
+// front. Mergers with other tag options miss these entries; use
+// Merger.PreloadTypes for them.
+func PreloadTypes(types ...interface{}) {
+	NewMerger(WithCache(defaultCache)).PreloadTypes(types...)
+}
+
+// PreloadTypes parses and caches the smap tags of the destination struct types
+// of the given example values (e.g. Config{} or (*Config)(nil)), so that
+// latency-sensitive services pay the reflection cost up front. Plans are
+// cached under the options of m, so later merges by m, or by Mergers sharing
+// its Cache and tag options, hit them. Values that are not structs or pointers
+// to structs are ignored, and PreloadTypes is a no-op when m has no Cache (see
+// WithCache).
+func (m *Merger) PreloadTypes(types ...interface{}) {
+	if m.cfg.cache == nil {
+		return
+	}
+	for _, v := range types {
+		typ := reflect.TypeOf(v)
+		for typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ != nil && typ.Kind() == reflect.Struct {
+			m.cfg.fieldPlans(typ)
+		}
+	}
+}
+
+// cacheKey identifies the field plans of a destination type.
+type cacheKey struct {
+	typ         reflect.Type
+	tagKey      string
+	fallbackKey string
+	splitter    TagSplitter
+}
+
+// fieldPlan describes a tagged destination field. A tag that fails to parse
+// is kept as err so that it is reported when the field is reached.
+type fieldPlan struct {
+	index int
+	name  string
+	raw   string
+	tag   *sTag
+	err   error
+}
+
+// fieldPlans returns the plans for the tagged fields of dstType in field
+// order, consulting and filling cfg.cache when one is set.
+func (cfg *config) fieldPlans(dstType reflect.Type) []fieldPlan {
+	tagKey := cfg.tagKeyFor(dstType)
+	if cfg.cache == nil {
+		return newFieldPlans(dstType, tagKey, cfg.fallbackTagKey, cfg.tagSplitter)
+	}
+
+	key := cacheKey{typ: dstType, tagKey: tagKey, fallbackKey: cfg.fallbackTagKey, splitter: cfg.tagSplitter}
+	cfg.cache.mu.RLock()
+	plans, ok := cfg.cache.plans[key]
+	cfg.cache.mu.RUnlock()
+	if ok {
+		if cfg.stats != nil {
+			cfg.stats.CacheHits.Add(1)
+		}
+		return plans
+	}
+	if cfg.stats != nil {
+		cfg.stats.CacheMisses.Add(1)
+	}
+
+	plans = newFieldPlans(dstType, tagKey, cfg.fallbackTagKey, cfg.tagSplitter)
+	cfg.cache.mu.Lock()
+	cfg.cache.plans[key] = plans
+	cfg.cache.mu.Unlock()
+	return plans
+}
+
+// newFieldPlans parses the tags of the fields of dstType that carry tagKey, or
+// fallbackKey when they do not.
+func newFieldPlans(dstType reflect.Type, tagKey, fallbackKey string, sp TagSplitter) []fieldPlan {
+	var plans []fieldPlan
+	for i := 0; i < dstType.NumField(); i++ {
+		field := dstType.Field(i)
+		rawTag, ok := lookupTag(field, tagKey, fallbackKey)
+		if !ok {
+			continue
+		}
+		tag, err := newSTag(rawTag, sp)
+		plans = append(plans, fieldPlan{index: i, name: field.Name, raw: rawTag, tag: tag, err: err})
+	}
+	return plans
+}
+
+// lookupTag returns the tag of field under tagKey, or under fallbackKey when
+// the field has no tagKey tag at all. An empty fallbackKey is not consulted.
+func lookupTag(field reflect.StructField, tagKey, fallbackKey string) (string, bool) {
+	if rawTag, ok := field.Tag.Lookup(tagKey); ok || fallbackKey == "" {
+		return rawTag, ok
+	}
+	return field.Tag.Lookup(fallbackKey)
+}
@@ -0,0 +1,211 @@
+package smap
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"reflect"
+	"sync"
+
+	"github.com/daved/vtypes"
+)
+
+// Converter converts a raw string by filling dst, a pointer to a new zero
+// value of the type it is registered for.
+type Converter func(dst interface{}, raw string) error
+
+// Parser parses a raw string into a new value of the type it is registered
+// for. Unlike a Converter, which fills an allocated value, a Parser constructs
+// the value itself, which suits types built by a package function.
+type Parser func(raw string) (interface{}, error)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[reflect.Type]Converter{}
+	parsers      = map[reflect.Type]Parser{}
+)
+
+// RegisterConverter makes conv the first mechanism tried when hydrating a
+// string into typ. Registering an existing type replaces its converter.
+func RegisterConverter(typ reflect.Type, conv Converter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[typ] = conv
+}
+
+// RegisterParser makes parse the mechanism tried after any Converter when
+// hydrating a string into typ. The parsed value must be assignable to typ.
+// Registering an existing type replaces its parser.
+func RegisterParser(typ reflect.Type, parse Parser) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	parsers[typ] = parse
+}
+
+// lookupConverter returns the Converter registered for typ.
+func lookupConverter(typ reflect.Type) (Converter, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	conv, ok := converters[typ]
+	return conv, ok && conv != nil
+}
+
+// lookupParser returns the Parser registered for typ.
+func lookupParser(typ reflect.Type) (Parser, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	parse, ok := parsers[typ]
+	return parse, ok && parse != nil
+}
+
+// isRegistered checks if a Converter or Parser is registered for typ.
+func isRegistered(typ reflect.Type) bool {
+	_, convOK := lookupConverter(typ)
+	_, parseOK := lookupParser(typ)
+	return convOK || parseOK
+}
+
+// hydrator hydrates a string into the destination type, reporting whether it
+// applies to that type at all.
+type hydrator func(dstType reflect.Type, srcString string) (reflect.Value, bool, error)
+
+// registeredHydrators are tried in order by registeredElement.
+var registeredHydrators = []hydrator{
+	convertedElement,
+	parsedElement,
+}
+
+// hydrators are tried in order by chainedElement.
+var hydrators = []hydrator{
+	textElement,
+	flagValueElement,
+	vtypesElement,
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+)
+
+// registeredElement hydrates a string into the destination type with the
+// Converter, then the Parser, registered for it, reporting whether either is
+// registered. If all registered ones fail, their errors are joined.
+func registeredElement(dstType reflect.Type, srcString string) (reflect.Value, bool, error) {
+	return firstElement(registeredHydrators, dstType, srcString)
+}
+
+// chainedElement hydrates a string into the destination type with the first
+// applicable hydrator that succeeds, in order: encoding.TextUnmarshaler,
+// flag.Value, then vtypes.Hydrate. If all of them fail, their errors are
+// joined.
+func chainedElement(dstType reflect.Type, srcString string) (reflect.Value, error) {
+	hydrated, _, err := firstElement(hydrators, dstType, srcString)
+	return hydrated, err
+}
+
+// firstElement hydrates with the first of hs that applies to the destination
+// type and succeeds, reporting whether any applied. If all applicable ones
+// fail, their errors are joined.
+func firstElement(hs []hydrator, dstType reflect.Type, srcString string) (reflect.Value, bool, error) {
+	var errs []error
+	for _, hydrate := range hs {
+		hydrated, ok, err := hydrate(dstType, srcString)
+		if !ok {
+			continue
+		}
+		if err == nil {
+			return hydrated, true, nil
+		}
+		errs = append(errs, err)
+	}
+	return reflect.Value{}, len(errs) > 0, errors.Join(errs...)
+}
+
+// convertedElement hydrates with the Converter registered for dstType.
+func convertedElement(dstType reflect.Type, srcString string) (reflect.Value, bool, error) {
+	conv, ok := lookupConverter(dstType)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+	ptr := reflect.New(dstType)
+	if err := conv(ptr.Interface(), srcString); err != nil {
+		return reflect.Value{}, true, err
+	}
+	return ptr.Elem(), true, nil
+}
+
+// parsedElement hydrates with the Parser registered for dstType.
+func parsedElement(dstType reflect.Type, srcString string) (reflect.Value, bool, error) {
+	parse, ok := lookupParser(dstType)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+	v, err := parse(srcString)
+	if err != nil {
+		return reflect.Value{}, true, err
+	}
+	if v == nil {
+		return reflect.Zero(dstType), true, nil
+	}
+	return reflect.ValueOf(v), true, nil
+}
+
+// textElement hydrates destinations implementing encoding.TextUnmarshaler.
+// Pointer destinations are allocated.
+func textElement(dstType reflect.Type, srcString string) (reflect.Value, bool, error) {
+	ptr, ok := implementerElement(dstType, textUnmarshalerType)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+	if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(srcString)); err != nil {
+		return reflect.Value{}, true, err
+	}
+	return implementedElement(dstType, ptr), true, nil
+}
+
+// flagValueElement hydrates destinations implementing flag.Value. Pointer
+// destinations are allocated.
+func flagValueElement(dstType reflect.Type, srcString string) (reflect.Value, bool, error) {
+	ptr, ok := implementerElement(dstType, flagValueType)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+	if err := ptr.Interface().(flag.Value).Set(srcString); err != nil {
+		return reflect.Value{}, true, err
+	}
+	return implementedElement(dstType, ptr), true, nil
+}
+
+// vtypesElement hydrates with vtypes.Hydrate, which applies to any type.
+func vtypesElement(dstType reflect.Type, srcString string) (reflect.Value, bool, error) {
+	hydratedPtr := reflect.New(dstType)
+	if err := vtypes.Hydrate(hydratedPtr.Interface(), srcString); err != nil {
+		return reflect.Value{}, true, err
+	}
+	return hydratedPtr.Elem(), true, nil
+}
+
+// implementerElement allocates a pointer implementing iface for the
+// destination type: a new pointed-to value for pointer destinations, or a
+// pointer to a new value otherwise.
+func implementerElement(dstType, iface reflect.Type) (reflect.Value, bool) {
+	if dstType.Kind() == reflect.Interface {
+		return reflect.Value{}, false
+	}
+	if dstType.Kind() == reflect.Ptr && dstType.Implements(iface) {
+		return reflect.New(dstType.Elem()), true
+	}
+	if reflect.PtrTo(dstType).Implements(iface) {
+		return reflect.New(dstType), true
+	}
+	return reflect.Value{}, false
+}
+
+// implementedElement returns the value of the destination type held by ptr,
+// as allocated by implementerElement.
+func implementedElement(dstType reflect.Type, ptr reflect.Value) reflect.Value {
+	if ptr.Type() == dstType {
+		return ptr
+	}
+	return ptr.Elem()
+}
@@ -0,0 +1,79 @@
+package smap
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// Decoder decodes a source document into a generic map.
+type Decoder func([]byte) (map[string]interface{}, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{
+		"json": decodeJSON,
+	}
+)
+
+// RegisterDecoder makes a Decoder available to MergeReader under the format
+// name. Registering an existing format replaces its decoder.
+func RegisterDecoder(format string, dec Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[format] = dec
+}
+
+// lookupDecoder returns the Decoder registered for format.
+func lookupDecoder(format string) (Decoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	dec, ok := decoders[format]
+	return dec, ok && dec != nil
+}
+
+// decodeJSON decodes a JSON object into a generic map. Numbers are kept as
+// json.Number so that they convert exactly into the destination type.
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MergeReader reads a source document from r, decodes it with the Decoder
+// registered for format (e.g. "json"), and merges the decoded map into dst.
+// Tag paths address the document's keys (e.g. "server.port").
+func MergeReader(dst interface{}, r io.Reader, format string, opts ...Option) error {
+	return NewMerger(opts...).MergeReader(dst, r, format)
+}
+
+// MergeReader reads and decodes a source document from r and merges it into
+// dst. See the package-level MergeReader for details.
+func (m *Merger) MergeReader(dst interface{}, r io.Reader, format string) error {
+	dstVal, err := makeDstValue(dst)
+	if err != nil {
+		return err
+	}
+
+	dec, ok := lookupDecoder(format)
+	if !ok {
+		return ErrFormatUnknown
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	doc, err := dec(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = mergeFields(m.cfg, dstVal, reflect.ValueOf(doc))
+	return err
+}
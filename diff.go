@@ -0,0 +1,51 @@
+package smap
+
+import (
+	"reflect"
+)
+
+// DiffStructs returns the names of the tagged fields whose values differ
+// between a and b, compared with reflect.DeepEqual, in field order. Untagged
+// and unexported fields are ignored, so the diff covers only the state smap
+// merges. a and b must be structs, or non-nil pointers to structs, of the same
+// type; anything else is ErrDiffInvalid.
+func DiffStructs(a, b interface{}, opts ...Option) ([]string, error) {
+	aVal, err := diffStructValue(a)
+	if err != nil {
+		return nil, err
+	}
+	bVal, err := diffStructValue(b)
+	if err != nil {
+		return nil, err
+	}
+	if aVal.Type() != bVal.Type() {
+		return nil, ErrDiffInvalid
+	}
+
+	var diff []string
+	for _, plan := range newConfig(opts...).fieldPlans(aVal.Type()) {
+		if !aVal.Type().Field(plan.index).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(aVal.Field(plan.index).Interface(), bVal.Field(plan.index).Interface()) {
+			diff = append(diff, plan.name)
+		}
+	}
+	return diff, nil
+}
+
+// diffStructValue follows v through any non-nil pointers to a struct and
+// returns the struct value.
+func diffStructValue(v interface{}) (reflect.Value, error) {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return reflect.Value{}, ErrDiffInvalid
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, ErrDiffInvalid
+	}
+	return val, nil
+}
@@ -9,12 +9,32 @@ import (
 var (
 	ErrDstInvalid             = errors.New("invalid dst: non-nil struct ptr required")
 	ErrSrcInvalid             = errors.New("invalid src: struct or non-nil ptr required")
+	ErrSrcNil                 = fmt.Errorf("%w: nil in src pointer chain", ErrSrcInvalid)
 	ErrTagInvalid             = errors.New("invalid path in tag")
+	ErrTagSplitterInvalid     = errors.New("tag splitter separators conflict")
 	ErrFieldTypesIncompatible = errors.New("source field type is incompatible with destination field type")
 	ErrTagEmpty               = errors.New("empty smap tag")
 	ErrTagPathNotFound        = errors.New("tag path field not found")
 	ErrTagPathEmpty           = errors.New("tag path is empty")
 	ErrTagPathInvalidKeyType  = errors.New("tag path key type cannot be converted") // Updated
+	ErrInterfaceTargetMissing = errors.New("no hydration target registered for interface type")
+	ErrFormatUnknown          = errors.New("no decoder registered for format")
+	ErrTooManyFields          = errors.New("dst has more tagged fields than allowed")
+	ErrLengthMismatch         = errors.New("dst and src lengths differ")
+	ErrFieldNotFound          = errors.New("tagged destination field not found")
+	ErrPathIncomplete         = errors.New("not all tag paths resolved")
+	ErrPathUnresolved         = errors.New("no tag path resolved")
+	ErrValueOverflow          = errors.New("value out of range for destination type")
+	ErrNumberOverflow         = fmt.Errorf("%w: number overflows", ErrValueOverflow)
+	ErrDurationUnitInvalid    = errors.New("unknown duration unit")
+	ErrIntBoolInvalid         = errors.New("integer is not a valid strict bool (0 or 1)")
+	ErrBoolInvalid            = errors.New("unrecognized bool token")
+	ErrSetterInvalid          = errors.New("setter method missing or not a single-input method")
+	ErrGatherLimitExceeded    = errors.New("path gathers more elements than allowed")
+	ErrDefaultFieldInvalid    = errors.New("default field missing or unexported")
+	ErrFieldCycle             = errors.New("fields reference each other in a cycle")
+	ErrDiffInvalid            = errors.New("diff operands must be structs of the same type")
+	ErrMapKeyInvalid          = errors.New("map key cannot be hydrated to destination key type")
 	// errKeepLooking is unexported for internal control flow
 	errKeepLooking = errors.New("keep looking for next path")
 )
@@ -23,6 +43,7 @@ var (
 type MergeFieldError struct {
 	child       error  // Unexported underlying error
 	TagValue    string // Relevant tag or path portion
+	FieldName   string // Destination field name, for failures without a tag
 	DstTypeName string // Destination type name
 	SrcTypeName string // Source type name
 }
@@ -37,8 +58,23 @@ func NewMergeFieldError(child error, tagValue, dstTypeName, srcTypeName string)
 	}
 }
 
+// NewFieldNameError constructs a MergeFieldError for the destination field
+// named fieldName, for failures that involve no tag (e.g. in Apply).
+func NewFieldNameError(child error, fieldName, dstTypeName, srcTypeName string) *MergeFieldError {
+	return &MergeFieldError{
+		child:       child,
+		FieldName:   fieldName,
+		DstTypeName: dstTypeName,
+		SrcTypeName: srcTypeName,
+	}
+}
+
 // Error implements the error interface.
 func (e *MergeFieldError) Error() string {
+	if e.FieldName != "" && e.TagValue == "" {
+		return fmt.Sprintf("merge field (field: %s, dst type: %s, src type: %s): %v",
+			e.FieldName, e.DstTypeName, e.SrcTypeName, e.child)
+	}
 	return fmt.Sprintf("merge field (tag: %q, dst type: %s, src type: %s): %v",
 		e.TagValue, e.DstTypeName, e.SrcTypeName, e.child)
 }
@@ -47,3 +83,53 @@ func (e *MergeFieldError) Error() string {
 func (e *MergeFieldError) Unwrap() error {
 	return e.child
 }
+
+// TagError is a complex error type for smap tag parsing failures.
+type TagError struct {
+	child  error  // Unexported underlying error
+	RawTag string // Unparsed tag value
+	Detail string // Description of the malformed portion
+}
+
+// NewTagError constructs a TagError with the given details.
+func NewTagError(child error, rawTag, detail string) *TagError {
+	return &TagError{
+		child:  child,
+		RawTag: rawTag,
+		Detail: detail,
+	}
+}
+
+// Error implements the error interface.
+func (e *TagError) Error() string {
+	return fmt.Sprintf("tag %q: %s: %v", e.RawTag, e.Detail, e.child)
+}
+
+// Unwrap returns the underlying error for errors.Is checks.
+func (e *TagError) Unwrap() error {
+	return e.child
+}
+
+// FieldErrors extracts every *MergeFieldError found in err's tree, in order.
+// Both singly wrapped errors and joined errors (those with an
+// "Unwrap() []error" method, such as errors.Join results) are traversed.
+func FieldErrors(err error) []*MergeFieldError {
+	var fieldErrs []*MergeFieldError
+	collectFieldErrors(err, &fieldErrs)
+	return fieldErrs
+}
+
+// collectFieldErrors appends the *MergeFieldErrors in err's tree to fieldErrs.
+func collectFieldErrors(err error, fieldErrs *[]*MergeFieldError) {
+	switch e := err.(type) {
+	case nil:
+	case *MergeFieldError:
+		*fieldErrs = append(*fieldErrs, e)
+	case interface{ Unwrap() []error }:
+		for _, child := range e.Unwrap() {
+			collectFieldErrors(child, fieldErrs)
+		}
+	case interface{ Unwrap() error }:
+		collectFieldErrors(e.Unwrap(), fieldErrs)
+	}
+}
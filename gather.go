@@ -0,0 +1,186 @@
+package smap
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// gatherElement gathers the contents of container into a new value of the map
+// destination type. Struct containers contribute their exported fields keyed
+// by Go field name (not by any tag); map containers contribute their entries
+// keyed as-is. When names is non-nil, only the listed fields or keys are
+// gathered; a listed struct field that does not exist is an error, while a
+// missing map key, or a field promoted through a nil embedded pointer, is
+// skipped. A nil pointer or nil map container leaves
+// nothing to gather. Gathering more entries than WithMaxSliceGather allows is
+// an error.
+func gatherElement(cfg *config, dstType reflect.Type, container reflect.Value, names []string) (reflect.Value, error) {
+	for container.Kind() == reflect.Ptr || container.Kind() == reflect.Interface {
+		if container.IsNil() {
+			return reflect.Value{}, errKeepLooking
+		}
+		container = container.Elem()
+	}
+	if container.Kind() == reflect.Map && container.IsNil() {
+		return reflect.Value{}, errKeepLooking
+	}
+	if dstType.Kind() != reflect.Map {
+		return reflect.Value{}, ErrFieldTypesIncompatible
+	}
+
+	gathered := reflect.MakeMap(dstType)
+	switch container.Kind() {
+	case reflect.Struct:
+		if names != nil {
+			for _, name := range names {
+				field, ok := container.Type().FieldByName(name)
+				if !ok || field.PkgPath != "" {
+					return reflect.Value{}, ErrTagPathNotFound
+				}
+				fieldVal, err := container.FieldByIndexErr(field.Index)
+				if err != nil {
+					continue // Promoted through a nil embedded pointer; unset
+				}
+				if err := setGathered(gathered, reflect.ValueOf(name), fieldVal); err != nil {
+					return reflect.Value{}, err
+				}
+			}
+			break
+		}
+		containerType := container.Type()
+		for i := 0; i < containerType.NumField(); i++ {
+			field := containerType.Field(i)
+			if field.PkgPath != "" || field.Anonymous {
+				continue
+			}
+			if err := setGathered(gathered, reflect.ValueOf(field.Name), container.Field(i)); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+
+	case reflect.Map:
+		if names != nil {
+			for _, name := range names {
+				key, err := mapKeyElement(container.Type().Key(), name)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				if val := container.MapIndex(key); val.IsValid() {
+					if err := setGathered(gathered, key, val); err != nil {
+						return reflect.Value{}, err
+					}
+				}
+			}
+			break
+		}
+		if cfg.maxGather > 0 && container.Len() > cfg.maxGather {
+			return reflect.Value{}, ErrGatherLimitExceeded
+		}
+		iter := container.MapRange()
+		for iter.Next() {
+			if err := setGathered(gathered, iter.Key(), iter.Value()); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+
+	default:
+		return reflect.Value{}, ErrFieldTypesIncompatible
+	}
+	if cfg.maxGather > 0 && gathered.Len() > cfg.maxGather {
+		return reflect.Value{}, ErrGatherLimitExceeded
+	}
+	return gathered, nil
+}
+
+// setGathered stores val under key in the gathered map, converting both to the
+// map's key and element types where this is lossless. String keys are
+// hydrated into numeric key types.
+func setGathered(gathered, key, val reflect.Value) error {
+	key, err := gatheredKey(gathered.Type().Key(), key)
+	if err != nil {
+		return err
+	}
+	val, ok := gatheredElement(gathered.Type().Elem(), val)
+	if !ok {
+		return ErrFieldTypesIncompatible
+	}
+	gathered.SetMapIndex(key, val)
+	return nil
+}
+
+// gatheredKey readies key for use as a key of keyType, parsing string keys
+// into numeric key types like map segments of tag paths.
+func gatheredKey(keyType reflect.Type, key reflect.Value) (reflect.Value, error) {
+	converted, ok := gatheredElement(keyType, key)
+	if ok {
+		return converted, nil
+	}
+	if converted.Kind() != reflect.String || !isNumericKey(keyType) {
+		return reflect.Value{}, ErrFieldTypesIncompatible
+	}
+	hydrated, err := mapKeyElement(keyType, converted.String())
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("%w: %q into %s", ErrMapKeyInvalid, converted.String(), keyType)
+	}
+	return hydrated, nil
+}
+
+// isNumericKey checks if keyType is an integer or float map key type.
+func isNumericKey(keyType reflect.Type) bool {
+	switch keyType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isKeyHydratable checks if a map of srcType must have its string keys
+// hydrated to be stored as dstType, a map with numeric keys.
+func isKeyHydratable(srcType, dstType reflect.Type) bool {
+	return srcType.Kind() == reflect.Map && dstType.Kind() == reflect.Map &&
+		srcType.Key().Kind() == reflect.String && isNumericKey(dstType.Key())
+}
+
+// keyedMapElement copies the src map into a new map of the destination type,
+// converting keys and elements with setGathered.
+func keyedMapElement(dstType reflect.Type, src reflect.Value) (reflect.Value, error) {
+	if src.IsNil() {
+		return reflect.Zero(dstType), nil
+	}
+	keyed := reflect.MakeMapWithSize(dstType, src.Len())
+	iter := src.MapRange()
+	for iter.Next() {
+		if err := setGathered(keyed, iter.Key(), iter.Value()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return keyed, nil
+}
+
+// gatheredElement readies v for storage as dstType, reporting whether it fits.
+func gatheredElement(dstType reflect.Type, v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if isLosslessConversion(v.Type(), dstType) {
+		v = v.Convert(dstType)
+	}
+	return v, v.Type().AssignableTo(dstType)
+}
+
+// mergeMapEntries copies the entries of src into the dstField map, allocating
+// the map first if it is nil. Existing entries not present in src are kept.
+func mergeMapEntries(dstField, src reflect.Value) {
+	if src.IsNil() {
+		return
+	}
+	if dstField.IsNil() {
+		dstField.Set(reflect.MakeMapWithSize(dstField.Type(), src.Len()))
+	}
+	iter := src.MapRange()
+	for iter.Next() {
+		dstField.SetMapIndex(iter.Key(), iter.Value())
+	}
+}
@@ -1,5 +1,5 @@
 module github.com/daved/smap
 
-go 1.18
+go 1.21
 
 require github.com/daved/vtypes v0.0.0-20250304043744-7dc0b006e1b0
@@ -0,0 +1,39 @@
+package smap
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Inspect returns a human-readable dump of how the smap tags of dst parse: the
+// type name, then each tagged field with its type, paths, and options. A tag
+// that fails to parse is shown with its error. dst may be a struct or a
+// pointer to one; an empty string is returned for anything else.
+func Inspect(dst interface{}, opts ...Option) string {
+	dstType := reflect.TypeOf(dst)
+	for dstType != nil && dstType.Kind() == reflect.Ptr {
+		dstType = dstType.Elem()
+	}
+	if dstType == nil || dstType.Kind() != reflect.Struct {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(dstType.String())
+	b.WriteString("\n")
+	for _, plan := range newConfig(opts...).fieldPlans(dstType) {
+		fmt.Fprintf(&b, "\t%s %s\n", plan.name, dstType.Field(plan.index).Type)
+		if plan.err != nil {
+			fmt.Fprintf(&b, "\t\terror: %v\n", plan.err)
+			continue
+		}
+		for _, pathParts := range plan.tag.pathsParts {
+			fmt.Fprintf(&b, "\t\tpath: %s\n", pathParts.Join(plan.tag.splitter))
+		}
+		for _, opt := range plan.tag.opts {
+			fmt.Fprintf(&b, "\t\toption: %s\n", opt)
+		}
+	}
+	return b.String()
+}
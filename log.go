@@ -0,0 +1,40 @@
+package smap
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logPath emits a debug record for the outcome of resolving a single tag
+// path. Nothing is emitted when no logger is set.
+func (cfg *config) logPath(tag *sTag, pathParts tagPathParts, outcome string, err error) {
+	if cfg.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("tag", tag.String()),
+		slog.String("path", pathParts.Join(tag.splitter)),
+		slog.String("outcome", outcome),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	cfg.logger.LogAttrs(context.Background(), slog.LevelDebug, "smap: path", attrs...)
+}
+
+// logField emits a debug record for the outcome of merging a destination
+// field. Nothing is emitted when no logger is set.
+func (cfg *config) logField(fieldName string, tag *sTag, outcome string, err error) {
+	if cfg.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("field", fieldName),
+		slog.String("tag", tag.String()),
+		slog.String("outcome", outcome),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	cfg.logger.LogAttrs(context.Background(), slog.LevelDebug, "smap: field", attrs...)
+}
@@ -0,0 +1,552 @@
+package smap
+
+import (
+	"log/slog"
+	"reflect"
+	"sync"
+)
+
+// Option configures merge behavior. Options are accepted by both Merge and
+// NewMerger so that a one-off merge and a reusable Merger behave the same.
+type Option func(*config)
+
+// config holds the settings applied by Options.
+type config struct {
+	tagKey             string
+	typeTagKeys        map[reflect.Type]string
+	fallbackTagKey     string
+	tagSplitter        TagSplitter
+	requireAllPaths    bool
+	emptyPathError     bool
+	fillOnlyZero       bool
+	preserveNonZero    bool
+	zeroAsAbsent       bool
+	defaultHydrate     bool
+	hydrateErrorWrap   bool
+	redactHydrateInput bool
+	strictAssign       bool
+	concurrentFields   int
+	maxFields          int
+	maxGather          int
+	preValidate        bool
+	cache              *Cache
+	renamePaths        map[string]string
+	pathPrefix         string
+	skipTypes          map[reflect.Type]bool
+	pathNotFound       PathNotFoundPolicy
+	nilSourceOK        bool
+	fieldOrder         []string
+	fieldAliases       map[string]string
+	logger             *slog.Logger
+	stats              *Stats
+	resolvedInto       map[string]interface{}
+	resolvedMu         *sync.Mutex
+	getterFallback     bool
+	preferMethod       bool
+	valueValidator     func(field string, v reflect.Value) error
+	valueTransform     func(field string, v reflect.Value) (reflect.Value, error)
+	assignHook         func(field string, old, new reflect.Value) bool
+	rawTag             func(field, rawTag string)
+	unknownPaths       func(field, path, failedSegment string)
+	allocMaps          bool
+	interfaceTargets   map[reflect.Type]reflect.Type
+}
+
+// newConfig constructs a config with opts applied in order.
+func newConfig(opts ...Option) *config {
+	cfg := &config{
+		tagKey: TagKey,
+	}
+	WithOptions(opts...)(cfg)
+	return cfg
+}
+
+// WithOptions bundles several options into one. Options are applied in
+// order, so later options override earlier ones. Nil options are ignored.
+func WithOptions(opts ...Option) Option {
+	return func(cfg *config) {
+		for _, opt := range opts {
+			if opt != nil {
+				opt(cfg)
+			}
+		}
+	}
+}
+
+// WithTagKey sets the struct tag key used to read tag paths, replacing
+// TagKey. An empty key is ignored.
+func WithTagKey(key string) Option {
+	return func(cfg *config) {
+		if key != "" {
+			cfg.tagKey = key
+		}
+	}
+}
+
+// WithTagKeyFallback reads tags under key for fields that have no tag under
+// the tag key (see WithTagKey and WithTagKeyForType). The keys are strictly
+// ordered rather than merged: a field tagged under the tag key never consults
+// key, even when that tag's paths do not resolve. This eases migrating struct
+// tags from one key to another. An empty key disables the fallback.
+func WithTagKeyFallback(key string) Option {
+	return func(cfg *config) {
+		cfg.fallbackTagKey = key
+	}
+}
+
+// WithTagSplitter sets the separators used to parse tags, replacing the
+// default grammar ("|" between paths, "," before and between options, and "."
+// between path segments). Empty separators keep their defaults. Separators
+// that overlap (e.g. "." for both paths and segments) or contain a quote or
+// brace are rejected: every tag then fails with ErrTagSplitterInvalid.
+func WithTagSplitter(sp TagSplitter) Option {
+	return func(cfg *config) {
+		cfg.tagSplitter = sp
+	}
+}
+
+// WithTagKeyForType sets struct tag keys for specific destination struct
+// types, overriding the Merger's tag key for those types. This allows
+// composing struct types that follow different tag conventions.
+func WithTagKeyForType(keys map[reflect.Type]string) Option {
+	return func(cfg *config) {
+		if cfg.typeTagKeys == nil {
+			cfg.typeTagKeys = make(map[reflect.Type]string, len(keys))
+		}
+		for typ, key := range keys {
+			cfg.typeTagKeys[typ] = key
+		}
+	}
+}
+
+// tagKeyFor returns the struct tag key used for the given destination type.
+func (cfg *config) tagKeyFor(typ reflect.Type) string {
+	if key, ok := cfg.typeTagKeys[typ]; ok && key != "" {
+		return key
+	}
+	return cfg.tagKey
+}
+
+// dstField returns the field of the destination struct type named name, or
+// by the field name name is an alias of (see WithFieldAlias).
+func (cfg *config) dstField(typ reflect.Type, name string) (reflect.StructField, bool) {
+	if field, ok := typ.FieldByName(name); ok {
+		return field, true
+	}
+	if alias, ok := cfg.fieldAliases[name]; ok {
+		return typ.FieldByName(alias)
+	}
+	return reflect.StructField{}, false
+}
+
+// dstFieldName returns the actual name of the field of typ referenced by name,
+// or name itself when it references no field.
+func (cfg *config) dstFieldName(typ reflect.Type, name string) string {
+	if field, ok := cfg.dstField(typ, name); ok {
+		return field.Name
+	}
+	return name
+}
+
+// dstFieldNames applies dstFieldName to each of names.
+func (cfg *config) dstFieldNames(typ reflect.Type, names []string) []string {
+	if len(cfg.fieldAliases) == 0 {
+		return names
+	}
+	actual := make([]string, len(names))
+	for i, name := range names {
+		actual[i] = cfg.dstFieldName(typ, name)
+	}
+	return actual
+}
+
+// skipsType reports whether fields of typ are skipped (see WithSkipTypes).
+func (cfg *config) skipsType(typ reflect.Type) bool {
+	return cfg.skipTypes[typ]
+}
+
+// skipsZero reports whether zero values resolved for tag are skipped, either
+// by the "skipzero" option or by WithZeroAsAbsent.
+func (cfg *config) skipsZero(tag *sTag) bool {
+	return tag.HasSkipZero() || (cfg.zeroAsAbsent && !tag.HasNoZeroSkip())
+}
+
+// recordResolved stores the raw value merged into the named field when
+// WithResolvedInto is set.
+func (cfg *config) recordResolved(fieldName string, rawValue reflect.Value) {
+	if cfg.resolvedInto == nil || !rawValue.CanInterface() {
+		return
+	}
+	cfg.resolvedMu.Lock()
+	defer cfg.resolvedMu.Unlock()
+	cfg.resolvedInto[fieldName] = rawValue.Interface()
+}
+
+// TagKeyFor returns the struct tag key that results from applying opts.
+func TagKeyFor(opts ...Option) string {
+	return newConfig(opts...).tagKey
+}
+
+// WithRequireAllPathsResolve requires every path listed in a tag to resolve,
+// as if each tag carried the "allpaths" option. A path that does not resolve
+// fails the merge with ErrPathIncomplete.
+func WithRequireAllPathsResolve() Option {
+	return func(cfg *config) {
+		cfg.requireAllPaths = true
+	}
+}
+
+// WithEmptyPathError fails the merge with ErrPathUnresolved when none of a
+// tag's paths resolves to a value for a non-pointer destination field. Tags
+// that skip zero values (see "skipzero" and WithZeroAsAbsent) are exempt, as
+// are fields filled through "fallbackenv" or "defaultfield".
+func WithEmptyPathError() Option {
+	return func(cfg *config) {
+		cfg.emptyPathError = true
+	}
+}
+
+// WithFillOnlyZero limits merging to destination fields that currently hold
+// their type's zero value. Fields that are already populated are left as-is,
+// which is useful when layering values onto an existing struct.
+func WithFillOnlyZero() Option {
+	return func(cfg *config) {
+		cfg.fillOnlyZero = true
+	}
+}
+
+// WithPreserveNonZeroDefaults keeps a populated destination field when the
+// value resolved for it is zero. Unlike "skipzero", which moves on to the next
+// path, the zero value still wins over earlier paths; it is only kept from
+// replacing a non-zero default. Unlike WithFillOnlyZero, non-zero values still
+// overwrite. Tags with the "nozeroskip" option assign zero values as usual.
+func WithPreserveNonZeroDefaults() Option {
+	return func(cfg *config) {
+		cfg.preserveNonZero = true
+	}
+}
+
+// WithPreValidate checks every tag of the destination struct (including any
+// "setter" methods) before merging any field, so a malformed tag never leaves
+// dst partially merged. By default, tags are checked as their fields are
+// merged.
+func WithPreValidate() Option {
+	return func(cfg *config) {
+		cfg.preValidate = true
+	}
+}
+
+// WithConcurrentFields merges up to n fields at a time. Each field writes a
+// distinct destination field, so this only pays off when resolving involves
+// expensive source method calls. Source methods must be safe for concurrent
+// use when this is enabled, as must the functions set by WithValueTransform,
+// WithValueValidator, WithAssignHook, and WithUnknownPaths. Fields with a
+// "setter" method are merged one at a time after the others, since setters
+// share the receiver. Unlike sequential merges, which stop at the first error,
+// every field is merged before the first error (in field order) is returned,
+// so a failed merge may leave more of dst changed. Values of n below 2 merge
+// sequentially.
+func WithConcurrentFields(n int) Option {
+	return func(cfg *config) {
+		cfg.concurrentFields = n
+	}
+}
+
+// WithZeroAsAbsent applies "skipzero" to every tag: zero resolved values are
+// treated as absent and the next path is used instead. Tags with the
+// "nozeroskip" option opt out.
+func WithZeroAsAbsent() Option {
+	return func(cfg *config) {
+		cfg.zeroAsAbsent = true
+	}
+}
+
+// WithMaxFields limits the number of tagged fields a destination struct may
+// have. Merging into a struct with more tagged fields fails with
+// ErrTooManyFields before any field is merged. A non-positive n means no limit,
+// which is the default.
+func WithMaxFields(n int) Option {
+	return func(cfg *config) {
+		cfg.maxFields = n
+	}
+}
+
+// WithMaxSliceGather limits the number of elements a gathering ("*" or a pick
+// segment) or projecting ("#") path may collect into a destination map or
+// slice. Collecting more fails with ErrGatherLimitExceeded. A non-positive n
+// means no limit, which is the default.
+func WithMaxSliceGather(n int) Option {
+	return func(cfg *config) {
+		cfg.maxGather = n
+	}
+}
+
+// WithCache makes the Merger read and store parsed tags in c, which may be
+// shared with other Mergers. A nil c disables caching, which is the default.
+func WithCache(c *Cache) Option {
+	return func(cfg *config) {
+		cfg.cache = c
+	}
+}
+
+// WithRenamePaths rewrites tag path prefixes before they are resolved, so that
+// renamed source fields can be followed without editing tags. Keys and values
+// are paths written with the tag's segment separator (e.g. "EV.OldName" to
+// "EV.NewName"), and may quote segments as tags do; a key matches whole
+// leading segments only, and the longest matching key wins.
+func WithRenamePaths(renames map[string]string) Option {
+	return func(cfg *config) {
+		if cfg.renamePaths == nil {
+			cfg.renamePaths = make(map[string]string, len(renames))
+		}
+		for from, to := range renames {
+			cfg.renamePaths[from] = to
+		}
+	}
+}
+
+// WithPathPrefix scopes every tag path under prefix, which is prepended
+// before resolving (e.g. with "Prod", "EV.URL" resolves "Prod.EV.URL"). The
+// prefix is written with the tag's segment separator and is applied after
+// WithRenamePaths. This lets one destination type read from differently
+// scoped copies of the same source.
+func WithPathPrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.pathPrefix = prefix
+	}
+}
+
+// WithSkipTypes makes merges leave destination fields of the given types
+// untouched, even when tagged. Skipped fields are not reported as unresolved.
+func WithSkipTypes(types ...reflect.Type) Option {
+	return func(cfg *config) {
+		if cfg.skipTypes == nil {
+			cfg.skipTypes = make(map[reflect.Type]bool, len(types))
+		}
+		for _, typ := range types {
+			cfg.skipTypes[typ] = true
+		}
+	}
+}
+
+// PathNotFoundPolicy determines how a tag path whose final segment names no
+// field or method of a source struct is handled.
+type PathNotFoundPolicy int
+
+// PathNotFoundPolicy values.
+const (
+	// PathNotFoundError fails the merge with ErrTagPathNotFound (the default).
+	PathNotFoundError PathNotFoundPolicy = iota
+	// PathNotFoundKeep moves on to the next path, as for unset values.
+	PathNotFoundKeep
+)
+
+// WithPathNotFoundPolicy sets how every tag handles a path whose final
+// segment names no field or method of a source struct.
+func WithPathNotFoundPolicy(policy PathNotFoundPolicy) Option {
+	return func(cfg *config) {
+		cfg.pathNotFound = policy
+	}
+}
+
+// WithNilSourceOK makes a nil source (or a nil anywhere in its pointer chain)
+// contribute nothing instead of failing with ErrSrcNil: dst is left unchanged
+// and no error is returned. MergeSlice skips nil source elements likewise.
+func WithNilSourceOK() Option {
+	return func(cfg *config) {
+		cfg.nilSourceOK = true
+	}
+}
+
+// WithFieldOrder makes merges process the named destination fields first, in
+// the given order, followed by the remaining fields in declaration order. A
+// field named by the "defaultfield" option of another is still processed
+// before it. Unresolved fields and errors are reported in processing order.
+func WithFieldOrder(names ...string) Option {
+	return func(cfg *config) {
+		cfg.fieldOrder = append([]string(nil), names...)
+	}
+}
+
+// WithFieldAlias maps names used to reference destination fields (e.g. "Url")
+// to the fields' actual names (e.g. "URL"). An alias is consulted only when no
+// field has the referenced name. It applies wherever a field is referenced by
+// name: the "defaultfield" tag option, WithFieldOrder, ResolvedValue, and Apply.
+func WithFieldAlias(aliases map[string]string) Option {
+	return func(cfg *config) {
+		if cfg.fieldAliases == nil {
+			cfg.fieldAliases = make(map[string]string, len(aliases))
+		}
+		for alias, name := range aliases {
+			cfg.fieldAliases[alias] = name
+		}
+	}
+}
+
+// WithLogger makes merges emit debug-level records to l: one per tag path
+// tried ("smap: path", with the tag, path, and outcome, including the winning
+// path) and one per field ("smap: field", with the field, tag, and outcome).
+// Nothing is logged when l is nil, which is the default.
+func WithLogger(l *slog.Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = l
+	}
+}
+
+// WithProfiling accumulates counters into stats during merges: tag paths
+// attempted, source methods called, and Cache hits and misses. Counting costs
+// an atomic increment per event; without this option nothing is counted.
+func WithProfiling(stats *Stats) Option {
+	return func(cfg *config) {
+		cfg.stats = stats
+	}
+}
+
+// WithDefaultHydrate applies "hydrate" to every tag whose destination is not a
+// string, so that string sources (e.g. environment variables) convert into
+// any hydratable type. Tags with the "nohydrate" option opt out.
+func WithDefaultHydrate() Option {
+	return func(cfg *config) {
+		cfg.defaultHydrate = true
+	}
+}
+
+// WithResolvedInto makes merges store the raw source value chosen for each
+// merged field in m, keyed by field name, before any conversion (e.g. the
+// string a "hydrate" field was parsed from). Fields that are not merged are
+// not stored. Writes to m are serialized, but m must not be accessed
+// elsewhere while a merge is running.
+func WithResolvedInto(m map[string]interface{}) Option {
+	return func(cfg *config) {
+		cfg.resolvedInto = m
+		cfg.resolvedMu = &sync.Mutex{}
+	}
+}
+
+// WithStrictAssignability disables every conversion of resolved values,
+// including those requested by tag options (e.g. "hydrate", "json", "trim"),
+// named-type conversion, and pointer allocation. A resolved value that is not
+// assignable to its destination field fails with ErrFieldTypesIncompatible.
+func WithStrictAssignability() Option {
+	return func(cfg *config) {
+		cfg.strictAssign = true
+	}
+}
+
+// WithHydrateErrorWrap includes the raw string that failed to hydrate in the
+// error, truncated to 64 runes, alongside the destination type reported by
+// MergeFieldError. See WithRedactHydrateInput for secrets.
+func WithHydrateErrorWrap() Option {
+	return func(cfg *config) {
+		cfg.hydrateErrorWrap = true
+	}
+}
+
+// WithRedactHydrateInput replaces the message of hydrate errors, from merges
+// and Apply, with "hydrate [redacted] failed", so that secrets never reach
+// error messages or logs; it takes precedence over WithHydrateErrorWrap. The
+// original error, which may quote the input, stays reachable through
+// errors.Is/As and Unwrap.
+func WithRedactHydrateInput() Option {
+	return func(cfg *config) {
+		cfg.redactHydrateInput = true
+	}
+}
+
+// WithGetterFallback makes a path segment that matches neither a field nor a
+// method fall back to a getter method named "Get" plus the segment (e.g.
+// "URL" resolves through GetURL). Getters follow the same signature rules as
+// other methods.
+func WithGetterFallback() Option {
+	return func(cfg *config) {
+		cfg.getterFallback = true
+	}
+}
+
+// WithPreferMethod resolves a struct segment "X" through a method named X
+// before a field named X. A declared method and a field promoted from an
+// embedded struct may share a name; by default the field wins.
+func WithPreferMethod() Option {
+	return func(cfg *config) {
+		cfg.preferMethod = true
+	}
+}
+
+// WithValueValidator sets a function that checks each resolved value just
+// before it is assigned to the named destination field. A non-nil error
+// aborts the merge and is returned wrapped in a MergeFieldError. With
+// WithConcurrentFields, fn may be called concurrently.
+func WithValueValidator(fn func(field string, v reflect.Value) error) Option {
+	return func(cfg *config) {
+		cfg.valueValidator = fn
+	}
+}
+
+// WithValueTransform sets a function that rewrites each resolved value (after
+// all tag options, such as hydrate, are applied) before it is validated and
+// assigned to the named destination field. The returned value must be
+// assignable to the field; returning an invalid reflect.Value leaves the field
+// unresolved. A non-nil error aborts the merge and is returned wrapped in a
+// MergeFieldError, unless the tag sets "onerror=skip". With
+// WithConcurrentFields, fn may be called concurrently.
+func WithValueTransform(fn func(field string, v reflect.Value) (reflect.Value, error)) Option {
+	return func(cfg *config) {
+		cfg.valueTransform = fn
+	}
+}
+
+// WithAssignHook sets a function that is called with the current and the new
+// value of the named destination field just before the new value is assigned,
+// after validation. Returning false vetoes the assignment: the field keeps its
+// current value and is reported as unresolved. With WithConcurrentFields, fn
+// may be called concurrently.
+func WithAssignHook(fn func(field string, old, new reflect.Value) bool) Option {
+	return func(cfg *config) {
+		cfg.assignHook = fn
+	}
+}
+
+// WithRawTag sets a function that is called with the name and the unparsed
+// smap tag of each tagged destination field, in merge order, before any field
+// is merged or its parsed tag is checked. Parsed tags may be cached (see
+// WithCache), but fn is called on every merge.
+func WithRawTag(fn func(field, rawTag string)) Option {
+	return func(cfg *config) {
+		cfg.rawTag = fn
+	}
+}
+
+// WithUnknownPaths sets a function that is called for each tag path that
+// does not resolve, either because a segment matches no field or key or
+// because navigation reaches a nil value. It receives the destination field
+// name, the path as resolved (after WithRenamePaths and WithPathPrefix), and
+// the first segment that failed. Finding that segment resolves the path again,
+// calling any source methods along it. Use it to audit stale tags. With
+// WithConcurrentFields, fn may be called concurrently.
+func WithUnknownPaths(fn func(field, path, failedSegment string)) Option {
+	return func(cfg *config) {
+		cfg.unknownPaths = fn
+	}
+}
+
+// WithAllocMaps merges resolved maps (e.g. from a "*" gather) into map
+// destinations entry by entry rather than replacing them. A nil destination
+// map is allocated before entries are added; fields with no resolved value
+// are left nil.
+func WithAllocMaps() Option {
+	return func(cfg *config) {
+		cfg.allocMaps = true
+	}
+}
+
+// WithInterfaceTarget sets the concrete type to allocate and hydrate when a
+// "hydrate" destination field has the interface type ifaceType. The hydrated
+// value is assigned if concreteType implements ifaceType.
+func WithInterfaceTarget(ifaceType, concreteType reflect.Type) Option {
+	return func(cfg *config) {
+		if cfg.interfaceTargets == nil {
+			cfg.interfaceTargets = make(map[reflect.Type]reflect.Type)
+		}
+		cfg.interfaceTargets[ifaceType] = concreteType
+	}
+}
@@ -0,0 +1,64 @@
+package smap
+
+import (
+	"errors"
+	"reflect"
+)
+
+// projectElement resolves subParts against each element of the slice or array
+// container, collecting the results in order into a new value of the slice
+// destination type. Elements for which subParts does not resolve leave a zero
+// value in place, so projections of the same container stay parallel. A nil
+// container leaves nothing to project. Projecting more elements than
+// WithMaxSliceGather allows is an error.
+func projectElement(cfg *config, dstType reflect.Type, container reflect.Value, subParts tagPathParts, runes bool) (reflect.Value, error) {
+	for container.Kind() == reflect.Ptr || container.Kind() == reflect.Interface {
+		if container.IsNil() {
+			return reflect.Value{}, errKeepLooking
+		}
+		container = container.Elem()
+	}
+	if container.Kind() == reflect.Slice && container.IsNil() {
+		return reflect.Value{}, errKeepLooking
+	}
+	if dstType.Kind() != reflect.Slice {
+		return reflect.Value{}, ErrFieldTypesIncompatible
+	}
+	if container.Kind() != reflect.Slice && container.Kind() != reflect.Array {
+		return reflect.Value{}, ErrFieldTypesIncompatible
+	}
+
+	if cfg.maxGather > 0 && container.Len() > cfg.maxGather {
+		return reflect.Value{}, ErrGatherLimitExceeded
+	}
+
+	elemType := dstType.Elem()
+	projected := reflect.MakeSlice(dstType, container.Len(), container.Len())
+	for i := 0; i < container.Len(); i++ {
+		value := container.Index(i)
+		if subParts.IsEmpty() {
+			for (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && !value.IsNil() {
+				value = value.Elem()
+			}
+		} else {
+			var err error
+			value, err = resolvePath(cfg, value, elemType, subParts, runes)
+			if errors.Is(err, errKeepLooking) {
+				continue
+			}
+			if err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		if !value.IsValid() {
+			continue
+		}
+
+		value, ok := gatheredElement(elemType, value)
+		if !ok {
+			return reflect.Value{}, ErrFieldTypesIncompatible
+		}
+		projected.Index(i).Set(value)
+	}
+	return projected, nil
+}
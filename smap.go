@@ -2,142 +2,1698 @@
 package smap
 
 import (
+	"database/sql"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
 	"reflect"
+	"regexp"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Merge merges values from src into dst based on dst's smap struct tags.
+func Merge(dst, src interface{}, opts ...Option) error {
+	return NewMerger(opts...).Merge(dst, src)
+}
+
+// Merger merges values using a fixed set of options. A Merger is safe for
+// concurrent use.
+type Merger struct {
+	cfg *config
+}
+
+// NewMerger constructs a Merger with the given options.
+func NewMerger(opts ...Option) *Merger {
+	return &Merger{
+		cfg: newConfig(opts...),
+	}
+}
+
+// Merge merges values from src into dst based on dst's smap struct tags.
+func (m *Merger) Merge(dst, src interface{}) error {
+	_, err := m.MergeWithResult(dst, src)
+	return err
+}
+
+// MergeWithResult merges like Merge and also reports the names of tagged
+// fields for which no path resolved (i.e. fields left untouched).
+func MergeWithResult(dst, src interface{}, opts ...Option) (unresolved []string, err error) {
+	return NewMerger(opts...).MergeWithResult(dst, src)
+}
+
+// MergeWithResult merges like Merge and also reports the names of tagged
+// fields for which no path resolved (i.e. fields left untouched).
+func (m *Merger) MergeWithResult(dst, src interface{}) (unresolved []string, err error) {
+	dstVal, err := makeDstValue(dst)
+	if err != nil {
+		return nil, err
+	}
+	return m.mergeValue(dstVal, reflect.ValueOf(src))
+}
+
+// MergeAddressable merges values from src into dst, which must be a settable
+// struct value (e.g. an element of a slice obtained through reflect) or a
+// non-nil pointer to a struct.
+func MergeAddressable(dst reflect.Value, src interface{}, opts ...Option) error {
+	return NewMerger(opts...).MergeAddressable(dst, src)
+}
+
+// MergeAddressable merges values from src into dst, which must be a settable
+// struct value or a non-nil pointer to a struct.
+func (m *Merger) MergeAddressable(dst reflect.Value, src interface{}) error {
+	dstVal, err := dstStructValue(dst)
+	if err != nil {
+		return err
+	}
+	_, err = m.mergeValue(dstVal, reflect.ValueOf(src))
+	return err
+}
+
+// mergeValue merges srcVal, which is resolved through any pointers and
+// interfaces to a struct, into the settable struct dstVal.
+func (m *Merger) mergeValue(dstVal, srcVal reflect.Value) ([]string, error) {
+	srcVal, err := srcStructValue(srcVal)
+	if err != nil {
+		if m.cfg.nilSourceOK && errors.Is(err, ErrSrcNil) {
+			return nil, nil // Contributes nothing
+		}
+		return nil, err
+	}
+	return mergeFields(m.cfg, dstVal, srcVal)
+}
+
+// ResolvedValue reports the value that the named field of dst would receive
+// from src, and whether any path resolved, without assigning it.
+func ResolvedValue(dst interface{}, fieldName string, src interface{}, opts ...Option) (interface{}, bool, error) {
+	return NewMerger(opts...).ResolvedValue(dst, fieldName, src)
+}
+
+// ResolvedValue reports the value that the named field of dst would receive
+// from src, and whether any path resolved, without assigning it.
+func (m *Merger) ResolvedValue(dst interface{}, fieldName string, src interface{}) (interface{}, bool, error) {
+	dstVal, err := makeDstValue(dst)
+	if err != nil {
+		return nil, false, err
+	}
+
+	srcVal, err := makeSrcValue(src)
+	if err != nil {
+		if m.cfg.nilSourceOK && errors.Is(err, ErrSrcNil) {
+			return nil, false, nil // Contributes nothing
+		}
+		return nil, false, err
+	}
+
+	field, ok := m.cfg.dstField(dstVal.Type(), fieldName)
+	if !ok {
+		return nil, false, ErrFieldNotFound
+	}
+	rawTag, ok := lookupTag(field, m.cfg.tagKeyFor(dstVal.Type()), m.cfg.fallbackTagKey)
+	if !ok {
+		return nil, false, ErrFieldNotFound
+	}
+	tag, err := newSTag(rawTag, m.cfg.tagSplitter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	dstType := field.Type
+	setter, err := setterMethod(dstVal, tag)
+	if err != nil {
+		return nil, false, err
+	}
+	if setter.IsValid() {
+		dstType = setter.Type().In(0)
+	}
+
+	finalValue, err := resolveField(m.cfg, fieldName, dstType, srcVal, tag)
+	if err != nil || !finalValue.IsValid() {
+		return nil, false, err
+	}
+	return finalValue.Interface(), true, nil
+}
+
+// MergeSlice merges each element of srcs into the element of dsts at the same
+// index. dsts must be a slice of structs or of non-nil pointers to structs;
+// srcs must be a slice or array of structs or of non-nil pointers to structs.
+// If the lengths differ, ErrLengthMismatch is returned before any element is
+// merged.
+func MergeSlice(dsts, srcs interface{}, opts ...Option) error {
+	return NewMerger(opts...).MergeSlice(dsts, srcs)
+}
+
+// MergeSlice merges each element of srcs into the element of dsts at the same
+// index. See the package-level MergeSlice for details.
+func (m *Merger) MergeSlice(dsts, srcs interface{}) error {
+	dstsVal := reflect.ValueOf(dsts)
+	if dstsVal.Kind() != reflect.Slice {
+		return ErrDstInvalid
+	}
+	srcsVal := reflect.ValueOf(srcs)
+	if srcsVal.Kind() != reflect.Slice && srcsVal.Kind() != reflect.Array {
+		return ErrSrcInvalid
+	}
+	if dstsVal.Len() != srcsVal.Len() {
+		return ErrLengthMismatch
+	}
+
+	for i := 0; i < dstsVal.Len(); i++ {
+		dstVal, err := dstStructValue(dstsVal.Index(i))
+		if err != nil {
+			return err
+		}
+		if _, err := m.mergeValue(dstVal, srcsVal.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeSliceElems merges each element of src into the element of the slice
+// pointed to by dst at the same index. dst must be a non-nil pointer to a
+// slice of structs or of pointers to structs; src must be a slice or array of
+// structs or of non-nil pointers to structs, reached through any number of
+// non-nil pointers or interfaces. The destination slice is grown to the length
+// of src (nil pointer elements are allocated before they are merged); extra
+// destination elements are left untouched. With WithNilSourceOK, a nil source
+// element is skipped, leaving its destination element as is.
+func MergeSliceElems(dst, src interface{}, opts ...Option) error {
+	return NewMerger(opts...).MergeSliceElems(dst, src)
+}
+
+// MergeSliceElems merges each element of src into the element of the slice
+// pointed to by dst at the same index. See the package-level MergeSliceElems
+// for details.
+func (m *Merger) MergeSliceElems(dst, src interface{}) error {
+	dstVal := reflect.ValueOf(dst)
+	if dstVal.Kind() != reflect.Ptr || dstVal.IsNil() || dstVal.Elem().Kind() != reflect.Slice {
+		return ErrDstInvalid
+	}
+	dstsVal := dstVal.Elem()
+	structType := dstsVal.Type().Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return ErrDstInvalid
+	}
+
+	srcsVal, err := srcSliceValue(reflect.ValueOf(src))
+	if err != nil {
+		if m.cfg.nilSourceOK && errors.Is(err, ErrSrcNil) {
+			return nil // Contributes nothing
+		}
+		return err
+	}
+
+	if n := srcsVal.Len(); dstsVal.Len() < n {
+		grow := reflect.MakeSlice(dstsVal.Type(), n-dstsVal.Len(), n-dstsVal.Len())
+		dstsVal.Set(reflect.AppendSlice(dstsVal, grow))
+	}
+
+	for i := 0; i < srcsVal.Len(); i++ {
+		srcElem, err := srcStructValue(srcsVal.Index(i))
+		if err != nil {
+			if m.cfg.nilSourceOK && errors.Is(err, ErrSrcNil) {
+				continue // Contributes nothing; a nil element stays nil
+			}
+			return err
+		}
+		elem := dstsVal.Index(i)
+		if elem.Kind() == reflect.Ptr && elem.IsNil() {
+			elem.Set(reflect.New(structType))
+		}
+		dstElem, err := dstStructValue(elem)
+		if err != nil {
+			return err
+		}
+		if _, err := mergeFields(m.cfg, dstElem, srcElem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// srcSliceValue follows srcVal through any number of pointers and interfaces
+// to a slice or array and returns it. A nil anywhere in the chain is
+// ErrSrcNil.
+func srcSliceValue(srcVal reflect.Value) (reflect.Value, error) {
+	if !srcVal.IsValid() {
+		return reflect.Value{}, ErrSrcNil
+	}
+	for srcVal.Kind() == reflect.Ptr || srcVal.Kind() == reflect.Interface {
+		if srcVal.IsNil() {
+			return reflect.Value{}, ErrSrcNil
+		}
+		srcVal = srcVal.Elem()
+	}
+	if srcVal.Kind() != reflect.Slice && srcVal.Kind() != reflect.Array {
+		return reflect.Value{}, ErrSrcInvalid
+	}
+	return srcVal, nil
+}
+
+// dstStructValue ensures dstVal is a settable struct or non-nil pointer to a
+// struct and returns the struct value.
+func dstStructValue(dstVal reflect.Value) (reflect.Value, error) {
+	if dstVal.Kind() == reflect.Ptr {
+		if dstVal.IsNil() {
+			return reflect.Value{}, ErrDstInvalid
+		}
+		dstVal = dstVal.Elem()
+	}
+	if dstVal.Kind() != reflect.Struct || !dstVal.CanSet() {
+		return reflect.Value{}, ErrDstInvalid
+	}
+	return dstVal, nil
+}
+
+// makeDstValue ensures dst is a non-nil pointer to a struct and returns its value.
+func makeDstValue(dst interface{}) (reflect.Value, error) {
+	dstVal := reflect.ValueOf(dst)
+	if dstVal.Kind() != reflect.Ptr {
+		return reflect.Value{}, ErrDstInvalid
+	}
+	return dstStructValue(dstVal)
+}
+
+// makeSrcValue ensures src is a struct, or a chain of non-nil pointers to a
+// struct, and returns the struct value.
+func makeSrcValue(src interface{}) (reflect.Value, error) {
+	return srcStructValue(reflect.ValueOf(src))
+}
+
+// srcStructValue follows srcVal through any number of pointers and interfaces
+// to a struct and returns the struct value. A nil anywhere in the chain is
+// ErrSrcNil.
+func srcStructValue(srcVal reflect.Value) (reflect.Value, error) {
+	if !srcVal.IsValid() {
+		return reflect.Value{}, ErrSrcNil
+	}
+	for srcVal.Kind() == reflect.Ptr || srcVal.Kind() == reflect.Interface {
+		if srcVal.IsNil() {
+			return reflect.Value{}, ErrSrcNil
+		}
+		srcVal = srcVal.Elem()
+	}
+	if srcVal.Kind() != reflect.Struct {
+		return reflect.Value{}, ErrSrcInvalid
+	}
+	return srcVal, nil
+}
+
+// mergeFields applies the smap tag mappings from srcVal to dstVal. The names
+// of tagged fields with no resolved value are returned.
+func mergeFields(cfg *config, dstVal, srcVal reflect.Value) ([]string, error) {
+	plans := cfg.fieldPlans(dstVal.Type())
+	if cfg.maxFields > 0 && len(plans) > cfg.maxFields {
+		return nil, ErrTooManyFields
+	}
+	plans = orderedPlans(plans, cfg.dstFieldNames(dstVal.Type(), cfg.fieldOrder))
+	if cfg.rawTag != nil {
+		for _, plan := range plans {
+			cfg.rawTag(plan.name, plan.raw)
+		}
+	}
+	if cfg.preValidate {
+		if err := validatePlans(plans, dstVal); err != nil {
+			return nil, err
+		}
+	}
+	plans, err := dependencyOrder(cfg, dstVal.Type(), plans)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.concurrentFields > 1 {
+		return mergeFieldsConcurrently(cfg, plans, dstVal, srcVal)
+	}
+
+	var unresolved []string
+	for _, plan := range plans {
+		if plan.err != nil {
+			return unresolved, plan.err
+		}
+		if cfg.skipsType(dstVal.Field(plan.index).Type()) {
+			continue // Managed by the caller
+		}
+		if cfg.fillOnlyZero && !dstVal.Field(plan.index).IsZero() {
+			continue // Already populated; only gaps are filled
+		}
+		setter, err := setterMethod(dstVal, plan.tag)
+		if err != nil {
+			return unresolved, err
+		}
+		resolved, err := mergeField(cfg, plan.name, dstVal, dstVal.Field(plan.index), setter, srcVal, plan.tag)
+		if err != nil {
+			return unresolved, err
+		}
+		if !resolved {
+			unresolved = append(unresolved, plan.name)
+		}
+	}
+	return unresolved, nil
+}
+
+// validatePlans checks the tags of plans, and the setter methods they name on
+// dstVal, returning the first error in plan order.
+func validatePlans(plans []fieldPlan, dstVal reflect.Value) error {
+	for _, plan := range plans {
+		if plan.err != nil {
+			return plan.err
+		}
+		if _, err := setterMethod(dstVal, plan.tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependencyOrder returns plans topologically ordered so that a tagged field
+// referenced by another (see fieldReferences) is merged before it, keeping the
+// order of plans otherwise. Fields referencing each other, or themselves, in a
+// cycle are an ErrFieldCycle. plans itself is not modified.
+func dependencyOrder(cfg *config, dstType reflect.Type, plans []fieldPlan) ([]fieldPlan, error) {
+	byName := make(map[string]int, len(plans))
+	for i, plan := range plans {
+		byName[plan.name] = i
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make([]int, len(plans))
+	ordered := make([]fieldPlan, 0, len(plans))
+	var chain []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		plan := plans[i]
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			for chain[0] != plan.name {
+				chain = chain[1:] // Fields leading into the cycle
+			}
+			detail := strings.Join(append(chain, plan.name), " -> ")
+			return NewTagError(ErrFieldCycle, plan.raw, "field cycle "+detail)
+		}
+		state[i] = visiting
+		chain = append(chain, plan.name)
+		for _, name := range fieldReferences(cfg, dstType, plan) {
+			if j, ok := byName[name]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		chain = chain[:len(chain)-1]
+		state[i] = visited
+		ordered = append(ordered, plan)
+		return nil
+	}
+	for i := range plans {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// fieldReferences returns the names of the destination fields of dstType that
+// the field of plan reads while it is merged: the field named by its
+// "defaultfield" option.
+func fieldReferences(cfg *config, dstType reflect.Type, plan fieldPlan) []string {
+	if plan.tag == nil {
+		return nil
+	}
+	if name, ok := plan.tag.DefaultField(); ok {
+		return []string{cfg.dstFieldName(dstType, name)}
+	}
+	return nil
+}
+
+// orderedPlans returns plans with the fields named in order first, in that
+// order, followed by the rest in declaration order. Names matching no plan are
+// ignored. plans itself is not modified.
+func orderedPlans(plans []fieldPlan, order []string) []fieldPlan {
+	if len(order) == 0 {
+		return plans
+	}
+	ordered := make([]fieldPlan, 0, len(plans))
+	placed := make(map[int]bool, len(order))
+	for _, name := range order {
+		for _, plan := range plans {
+			if plan.name == name && !placed[plan.index] {
+				ordered = append(ordered, plan)
+				placed[plan.index] = true
+			}
+		}
+	}
+	for _, plan := range plans {
+		if !placed[plan.index] {
+			ordered = append(ordered, plan)
+		}
+	}
+	return ordered
+}
+
+// fieldMerge tracks the outcome of merging a single destination field.
+type fieldMerge struct {
+	index    int
+	name     string
+	tag      *sTag
+	setter   reflect.Value
+	deferred bool // Merged after the others (see "defaultfield" and "setter")
+	resolved bool
+	err      error
+}
+
+// mergeFieldsConcurrently behaves like mergeFields, but merges fields across a
+// pool of cfg.concurrentFields workers. All tags are checked before any field
+// is merged. Each worker writes a distinct destination field, and errors are
+// reported in field order. Fields that default to another field, or that are
+// set through a setter method of the shared receiver, are merged sequentially
+// once the workers are done.
+func mergeFieldsConcurrently(cfg *config, plans []fieldPlan, dstVal, srcVal reflect.Value) ([]string, error) {
+	var merges []*fieldMerge
+	for _, plan := range plans {
+		if plan.err != nil {
+			return nil, plan.err
+		}
+		if cfg.skipsType(dstVal.Field(plan.index).Type()) {
+			continue // Managed by the caller
+		}
+		if cfg.fillOnlyZero && !dstVal.Field(plan.index).IsZero() {
+			continue // Already populated; only gaps are filled
+		}
+		setter, err := setterMethod(dstVal, plan.tag)
+		if err != nil {
+			return nil, err
+		}
+		_, deferred := plan.tag.DefaultField()
+		deferred = deferred || setter.IsValid() // Setters share the receiver
+		merges = append(merges, &fieldMerge{index: plan.index, name: plan.name, tag: plan.tag, setter: setter, deferred: deferred})
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cfg.concurrentFields)
+	for _, fm := range merges {
+		if fm.deferred {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(fm *fieldMerge) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fm.resolved, fm.err = mergeField(cfg, fm.name, dstVal, dstVal.Field(fm.index), fm.setter, srcVal, fm.tag)
+		}(fm)
+	}
+	wg.Wait()
+
+	for _, fm := range merges {
+		if fm.deferred {
+			fm.resolved, fm.err = mergeField(cfg, fm.name, dstVal, dstVal.Field(fm.index), fm.setter, srcVal, fm.tag)
+		}
+	}
+
+	var unresolved []string
+	for _, fm := range merges {
+		if fm.err != nil {
+			return unresolved, fm.err
+		}
+		if !fm.resolved {
+			unresolved = append(unresolved, fm.name)
+		}
+	}
+	return unresolved, nil
+}
+
+// mergeField sets dstField (named fieldName) of the dstVal struct based on the
+// smap tag paths in srcVal. It reports whether any path resolved to a value.
+// When none does, the value of the field of dstVal named by the
+// "defaultfield" option, if non-zero, is used instead.
+//
+// When setter is valid (see the "setter" tag option), the value is converted
+// to the setter's input type and passed to it instead of being set on
+// dstField.
+//
+// Errors are returned unless the tag sets "onerror=skip", in which case the
+// field keeps its current value and is reported as unresolved.
+func mergeField(cfg *config, fieldName string, dstVal, dstField, setter, srcVal reflect.Value, tag *sTag) (bool, error) {
+	dstType := dstField.Type()
+	if setter.IsValid() {
+		dstType = setter.Type().In(0)
+	}
+
+	rawValue, err := resolveRawField(cfg, fieldName, dstType, srcVal, tag)
+	if err == nil && !rawValue.IsValid() {
+		rawValue, err = defaultFieldElement(cfg, dstVal, tag)
+	}
+	if err == nil && !rawValue.IsValid() && cfg.emptyPathError && !cfg.skipsZero(tag) && dstType.Kind() != reflect.Ptr {
+		err = NewMergeFieldError(ErrPathUnresolved, tag.String(), dstType.String(), "")
+	}
+	mergesMap := !setter.IsValid() && tag.HasMerge() && isStructMap(dstType)
+	finalValue := rawValue
+	switch {
+	case err != nil || !rawValue.IsValid():
+	case mergesMap:
+		finalValue, err = mergedMapElement(cfg, dstField, rawValue)
+		if err != nil {
+			err = NewMergeFieldError(err, tag.String(), dstType.String(), rawValue.Type().String())
+		}
+	default:
+		finalValue, err = convertField(cfg, dstType, rawValue, tag)
+	}
+	if err != nil {
+		if tag.IsOnErrorSkip() {
+			cfg.logField(fieldName, tag, "skipped error", err)
+			return false, nil
+		}
+		cfg.logField(fieldName, tag, "failed", err)
+		return false, err
+	}
+	if !finalValue.IsValid() {
+		cfg.logField(fieldName, tag, "unresolved", nil)
+		return false, nil
+	}
+
+	if cfg.valueTransform != nil {
+		srcTypeName := finalValue.Type().String()
+		finalValue, err = cfg.valueTransform(fieldName, finalValue)
+		if err == nil && finalValue.IsValid() && !finalValue.Type().AssignableTo(dstType) {
+			err = ErrFieldTypesIncompatible
+		}
+		if err != nil {
+			if tag.IsOnErrorSkip() {
+				cfg.logField(fieldName, tag, "skipped error", err)
+				return false, nil
+			}
+			cfg.logField(fieldName, tag, "failed", err)
+			return true, NewMergeFieldError(err, tag.String(), dstType.String(), srcTypeName)
+		}
+		if !finalValue.IsValid() {
+			cfg.logField(fieldName, tag, "unresolved", nil)
+			return false, nil
+		}
+	}
+
+	if cfg.valueValidator != nil {
+		if err := cfg.valueValidator(fieldName, finalValue); err != nil {
+			if tag.IsOnErrorSkip() {
+				cfg.logField(fieldName, tag, "skipped error", err)
+				return false, nil
+			}
+			cfg.logField(fieldName, tag, "failed", err)
+			return true, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+	}
+
+	if cfg.preserveNonZero && !tag.HasNoZeroSkip() && finalValue.IsZero() && !dstField.IsZero() {
+		cfg.logField(fieldName, tag, "preserved", nil)
+		return true, nil // Resolved, but the populated destination wins
+	}
+
+	if cfg.assignHook != nil && !cfg.assignHook(fieldName, dstField, finalValue) {
+		cfg.logField(fieldName, tag, "vetoed", nil)
+		return false, nil
+	}
+
+	if setter.IsValid() {
+		if err := callSetter(setter, finalValue); err != nil {
+			if tag.IsOnErrorSkip() {
+				cfg.logField(fieldName, tag, "skipped error", err)
+				return false, nil
+			}
+			cfg.logField(fieldName, tag, "failed", err)
+			return true, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		cfg.recordResolved(fieldName, rawValue)
+		cfg.logField(fieldName, tag, "merged", nil)
+		return true, nil
+	}
+
+	if mergesMap {
+		applyMergedMap(dstField, finalValue)
+		cfg.recordResolved(fieldName, rawValue)
+		cfg.logField(fieldName, tag, "merged", nil)
+		return true, nil
+	}
+
+	if cfg.allocMaps && dstField.Kind() == reflect.Map {
+		mergeMapEntries(dstField, finalValue)
+		cfg.recordResolved(fieldName, rawValue)
+		cfg.logField(fieldName, tag, "merged", nil)
+		return true, nil
+	}
+
+	dstField.Set(finalValue)
+	cfg.recordResolved(fieldName, rawValue)
+	cfg.logField(fieldName, tag, "merged", nil)
+	return true, nil
+}
+
+// defaultFieldElement returns the value of the exported field of the dstVal
+// struct named by the "defaultfield" option of tag, or an invalid value when
+// the option is absent or the field is zero.
+func defaultFieldElement(cfg *config, dstVal reflect.Value, tag *sTag) (reflect.Value, error) {
+	name, ok := tag.DefaultField()
+	if !ok {
+		return reflect.Value{}, nil
+	}
+	field, ok := cfg.dstField(dstVal.Type(), name)
+	if !ok || field.PkgPath != "" {
+		return reflect.Value{}, NewMergeFieldError(ErrDefaultFieldInvalid, tag.String(), dstVal.Type().String()+"."+name, "")
+	}
+	value, err := dstVal.FieldByIndexErr(field.Index)
+	if err != nil || value.IsZero() {
+		return reflect.Value{}, nil // Promoted through a nil embed, or unset
+	}
+	return value, nil
+}
+
+// isStructMap checks if typ is a map of structs or of pointers to structs.
+func isStructMap(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Map {
+		return false
+	}
+	elemType := typ.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	return elemType.Kind() == reflect.Struct
+}
+
+// mergedMapElement merges each value of the srcMap map into a copy of the
+// value of the dstField map under the same key (see the "merge" tag option),
+// using the smap tags of the destination value type, and returns a copy of the
+// dstField map holding the results. Destination keys missing from srcMap, or
+// holding nil there, are left untouched, and source keys missing from
+// dstField are ignored; a nil dstField is instead filled from every source
+// key. An invalid value is returned when no destination field was written.
+func mergedMapElement(cfg *config, dstField, srcMap reflect.Value) (reflect.Value, error) {
+	for srcMap.Kind() == reflect.Ptr || srcMap.Kind() == reflect.Interface {
+		if srcMap.IsNil() {
+			return reflect.Value{}, nil
+		}
+		srcMap = srcMap.Elem()
+	}
+	if srcMap.Kind() != reflect.Map {
+		return reflect.Value{}, ErrFieldTypesIncompatible
+	}
+
+	var written atomic.Bool
+	elemCfg := *cfg
+	elemCfg.assignHook = func(field string, old, new reflect.Value) bool {
+		if cfg.assignHook != nil && !cfg.assignHook(field, old, new) {
+			return false
+		}
+		written.Store(true) // Nested merges may run concurrently
+		return true
+	}
+
+	dstType := dstField.Type()
+	elemType, structType := dstType.Elem(), dstType.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	merged := reflect.MakeMapWithSize(dstType, dstField.Len())
+	keys := dstField.MapKeys()
+	if dstField.IsNil() {
+		keys = keys[:0]
+		for _, srcKey := range srcMap.MapKeys() {
+			key, ok := gatheredElement(dstType.Key(), srcKey)
+			if !ok {
+				return reflect.Value{}, ErrTagPathInvalidKeyType
+			}
+			keys = append(keys, key)
+		}
+	} else {
+		mergeMapEntries(merged, dstField)
+	}
+
+	for _, key := range keys {
+		srcKey, ok := gatheredElement(srcMap.Type().Key(), key)
+		if !ok {
+			return reflect.Value{}, ErrTagPathInvalidKeyType
+		}
+		srcElem := srcMap.MapIndex(srcKey)
+		if !srcElem.IsValid() {
+			continue
+		}
+		srcStruct, err := srcStructValue(srcElem)
+		if errors.Is(err, ErrSrcNil) {
+			continue // Nothing to merge
+		}
+		if err != nil {
+			return reflect.Value{}, ErrFieldTypesIncompatible
+		}
+
+		elem := reflect.New(structType)
+		if dstElem := dstField.MapIndex(key); dstElem.IsValid() {
+			if elemType.Kind() == reflect.Ptr {
+				if dstElem.IsNil() {
+					continue // Nothing to merge into
+				}
+				dstElem = dstElem.Elem()
+			}
+			elem.Elem().Set(dstElem)
+		}
+		if _, err := mergeFields(&elemCfg, elem.Elem(), srcStruct); err != nil {
+			return reflect.Value{}, err
+		}
+		if elemType.Kind() != reflect.Ptr {
+			elem = elem.Elem()
+		}
+		merged.SetMapIndex(key, elem)
+	}
+	if !written.Load() {
+		return reflect.Value{}, nil
+	}
+	return merged, nil
+}
+
+// applyMergedMap stores the values of the merged map into the dstField map,
+// allocating it first if it is nil. Pointer values are copied into the
+// structs that dstField already points to, so those pointers stay valid.
+func applyMergedMap(dstField, merged reflect.Value) {
+	if dstField.IsNil() {
+		dstField.Set(merged)
+		return
+	}
+	iter := merged.MapRange()
+	for iter.Next() {
+		key, value := iter.Key(), iter.Value()
+		current := dstField.MapIndex(key)
+		if value.Kind() == reflect.Ptr && current.IsValid() && !current.IsNil() && !value.IsNil() {
+			current.Elem().Set(value.Elem())
+			continue
+		}
+		dstField.SetMapIndex(key, value)
+	}
+}
+
+// setterMethod returns the method of the destination struct dstVal named by
+// the "setter" option of tag, or an invalid value when the option is absent.
+// The method must take a single input and return nothing or an error.
+func setterMethod(dstVal reflect.Value, tag *sTag) (reflect.Value, error) {
+	name, ok := tag.Setter()
+	if !ok {
+		return reflect.Value{}, nil
+	}
+	method := dstVal.MethodByName(name)
+	if !method.IsValid() && dstVal.CanAddr() {
+		method = dstVal.Addr().MethodByName(name)
+	}
+	if !method.IsValid() {
+		return reflect.Value{}, NewMergeFieldError(ErrSetterInvalid, tag.String(), dstVal.Type().String()+"."+name, "")
+	}
+	methodType := method.Type()
+	validOut := methodType.NumOut() == 0 || (methodType.NumOut() == 1 && methodType.Out(0) == errorType)
+	if methodType.NumIn() != 1 || methodType.IsVariadic() || !validOut {
+		return reflect.Value{}, NewMergeFieldError(ErrSetterInvalid, tag.String(), methodType.String(), "")
+	}
+	return method, nil
+}
+
+// callSetter calls setter with value and returns the error it reports, if any.
+func callSetter(setter, value reflect.Value) error {
+	results := setter.Call([]reflect.Value{value})
+	if len(results) == 0 {
+		return nil
+	}
+	err, _ := results[0].Interface().(error)
+	return err
+}
+
+// resolveField resolves the value for the destination field fieldName of
+// dstType from the smap tag paths in srcVal, applying the tag options. An
+// invalid value is returned if no path resolved.
+func resolveField(cfg *config, fieldName string, dstType reflect.Type, srcVal reflect.Value, tag *sTag) (reflect.Value, error) {
+	rawValue, err := resolveRawField(cfg, fieldName, dstType, srcVal, tag)
+	if err != nil || !rawValue.IsValid() {
+		return rawValue, err
+	}
+	return convertField(cfg, dstType, rawValue, tag)
+}
+
+// resolveRawField resolves the source value chosen by the smap tag paths in
+// srcVal for the destination field fieldName of dstType, before any
+// conversion. An invalid value is returned if no path resolved.
+func resolveRawField(cfg *config, fieldName string, dstType reflect.Type, srcVal reflect.Value, tag *sTag) (reflect.Value, error) {
+	if tag.IsEmpty() {
+		return reflect.Value{}, NewMergeFieldError(ErrTagEmpty, "", dstType.String(), "")
+	}
+
+	rawValue, err := findLeafValueByPathsParts(cfg, fieldName, srcVal, dstType, tag)
+	if err != nil {
+		return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), "")
+	}
+
+	if name, ok := tag.FallbackEnv(); ok && !rawValue.IsValid() {
+		if envValue, ok := os.LookupEnv(name); ok {
+			rawValue = reflect.ValueOf(envValue) // No path resolved
+		}
+	}
+	return rawValue, nil
+}
+
+// convertField converts the valid raw value resolved for a destination field
+// of dstType, applying the tag options. With WithStrictAssignability, no
+// conversion is applied.
+func convertField(cfg *config, dstType reflect.Type, finalValue reflect.Value, tag *sTag) (reflect.Value, error) {
+	if cfg.strictAssign {
+		return assignableElement(dstType, finalValue, tag)
+	}
+
+	if finalValue.Kind() == reflect.Interface && finalValue.IsNil() && isNil(reflect.Zero(dstType)) {
+		return reflect.Zero(dstType), nil // A nil element (e.g. a NULL column) leaves nil
+	}
+
+	if transform := tag.StringTransform(); transform != nil {
+		finalValue = transformedElement(finalValue, transform)
+	}
+
+	if finalValue.Type() == jsonNumberType {
+		numberValue, err := jsonNumberElement(dstType, finalValue.Interface().(json.Number))
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = numberValue
+	}
+
+	if tag.HasBase64() && finalValue.Kind() == reflect.String && (isByteSlice(dstType) || isBinaryUnmarshaler(dstType)) {
+		decodeType := dstType
+		if !isByteSlice(dstType) {
+			decodeType = byteSliceType // Unmarshaled below
+		}
+		decodedValue, err := base64Element(decodeType, finalValue.String(), tag.IsBase64URL())
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = decodedValue
+	}
+
+	if isByteSlice(finalValue.Type()) && !isByteSlice(dstType) && isBinaryUnmarshaler(dstType) {
+		binaryValue, err := binaryElement(dstType, finalValue.Bytes())
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = binaryValue
+	}
+
+	if tag.HasJSON() && finalValue.Kind() == reflect.String {
+		decodedValue, err := jsonElement(dstType, finalValue.String())
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = decodedValue
+	}
+
+	if tag.HasStringer() && dstType.Kind() == reflect.String {
+		finalValue = stringerElement(finalValue)
+	}
+
+	registeredHydrate := hydrates(cfg, tag, dstType) && finalValue.Kind() == reflect.String && isRegistered(dstType)
+	if isScanner(dstType) && !finalValue.Type().AssignableTo(dstType) && !registeredHydrate {
+		scannedValue, err := scannerElement(dstType, finalValue)
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = scannedValue
+	}
+
+	if hydrates(cfg, tag, dstType) && isBoolType(dstType) && !tag.HasIntBool() && isInteger(finalValue.Kind()) {
+		finalValue = reflect.ValueOf(fmt.Sprint(finalValue.Interface())) // Hydrated as a token below
+	}
+
+	if hydrates(cfg, tag, dstType) && isRenumbered(finalValue.Type(), dstType) {
+		finalValue = reflect.ValueOf(numberString(finalValue)) // Range-checked by hydration below
+	}
+
+	if hydrates(cfg, tag, dstType) && finalValue.Kind() == reflect.String {
+		hydratedValue, err := hydratedElement(cfg, tag, dstType, finalValue.String())
+		if errors.Is(err, strconv.ErrRange) {
+			err = fmt.Errorf("%w: %w", ErrValueOverflow, err) // Sized destination exceeded
+		}
+		if err != nil {
+			err = hydrateInputError(cfg, finalValue.String(), err)
+		}
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = hydratedValue
+	}
+
+	if tag.HasIntBool() && dstType.Kind() == reflect.Bool {
+		boolValue, err := intBoolElement(dstType, finalValue, tag.IsIntBoolStrict())
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = boolValue
+	}
+
+	durType := dstType
+	if durType.Kind() == reflect.Ptr {
+		durType = durType.Elem() // Wrapped in a pointer below
+	}
+	if durType == durationType && finalValue.Type() != durationType && finalValue.Type() != dstType {
+		durationValue, err := durationElement(finalValue, tag.DurationUnit())
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = durationValue
+	}
+
+	if isKeyHydratable(finalValue.Type(), dstType) {
+		keyedValue, err := keyedMapElement(dstType, finalValue)
+		if err != nil {
+			return reflect.Value{}, NewMergeFieldError(err, tag.String(), dstType.String(), finalValue.Type().String())
+		}
+		finalValue = keyedValue
+	}
+
+	if isLosslessConversion(finalValue.Type(), dstType) {
+		finalValue = finalValue.Convert(dstType)
+	}
+
+	if dstType.Kind() == reflect.Ptr {
+		if tag.HasNilOnZero() && isZeroLeaf(finalValue, dstType) {
+			return reflect.Value{}, nil // Zero means unset; the field is left as is
+		}
+		finalValue = pointerElement(dstType, finalValue)
+	}
+
+	return assignableElement(dstType, finalValue, tag)
+}
+
+// assignableElement ensures srcVal is assignable to the destination type.
+func assignableElement(dstType reflect.Type, srcVal reflect.Value, tag *sTag) (reflect.Value, error) {
+	if !srcVal.Type().AssignableTo(dstType) {
+		return reflect.Value{}, NewMergeFieldError(ErrFieldTypesIncompatible, tag.String(), dstType.String(), srcVal.Type().String())
+	}
+	return srcVal, nil
+}
+
+// findLeafValueByPathsParts finds the last valid, non-zero leaf value from the given paths.
+// When all paths are required, any path that does not resolve is an error.
+// Paths that do not resolve are reported for fieldName to WithUnknownPaths.
+func findLeafValueByPathsParts(cfg *config, fieldName string, srcVal reflect.Value, dstType reflect.Type, tag *sTag) (reflect.Value, error) {
+	requireAll := cfg.requireAllPaths || tag.HasAllPaths()
+	skipZero := cfg.skipsZero(tag)
+	var finalValue reflect.Value
+	var winner tagPathParts
+	for _, pathParts := range tag.pathsParts {
+		pathParts = renamedPath(cfg, pathParts, tag.splitter.Segments)
+		pathParts = prefixedPath(cfg, pathParts, tag.splitter.Segments)
+		value, err := resolvePath(cfg, srcVal, dstType, pathParts, tag.HasRune())
+		if err != nil {
+			if cfg.unknownPaths != nil && (errors.Is(err, errKeepLooking) || errors.Is(err, ErrTagPathNotFound)) {
+				cfg.unknownPaths(fieldName, pathParts.Join(tag.splitter), failedSegment(cfg, srcVal, pathParts, tag.HasRune()))
+			}
+			if errors.Is(err, errKeepLooking) {
+				cfg.logPath(tag, pathParts, "unset", nil)
+				if requireAll {
+					return reflect.Value{}, ErrPathIncomplete
+				}
+				continue
+			}
+			cfg.logPath(tag, pathParts, "failed", err)
+			return reflect.Value{}, err
+		}
+		if requireAll && !value.IsValid() {
+			return reflect.Value{}, ErrPathIncomplete
+		}
+		if !value.IsValid() {
+			cfg.logPath(tag, pathParts, "unset", nil)
+			continue
+		}
+		if skipZero && isZeroLeaf(value, dstType) {
+			cfg.logPath(tag, pathParts, "skipped zero", nil)
+			continue
+		}
+		if tag.HasSkipNil() && isNil(value) {
+			cfg.logPath(tag, pathParts, "skipped nil", nil)
+			continue
+		}
+		cfg.logPath(tag, pathParts, "resolved", nil)
+		finalValue, winner = value, pathParts
+	}
+	if winner != nil {
+		cfg.logPath(tag, winner, "won", nil)
+	}
+	return finalValue, nil
+}
+
+// renamedPath rewrites the leading segments of pathParts using the longest
+// matching prefix registered with WithRenamePaths. Prefixes are compared
+// segment by segment, so quoted segments (e.g. `Old."a.b"`) stay intact.
+func renamedPath(cfg *config, pathParts tagPathParts, sep string) tagPathParts {
+	if len(cfg.renamePaths) == 0 {
+		return pathParts
+	}
+	var from, to tagPathParts
+	for prefix, rename := range cfg.renamePaths {
+		prefixParts := splitPath(prefix, sep)
+		if len(prefixParts) <= len(from) || !hasPathPrefix(pathParts, prefixParts) {
+			continue
+		}
+		from, to = prefixParts, splitPath(rename, sep)
+	}
+	if from == nil {
+		return pathParts
+	}
+	renamed := make(tagPathParts, 0, len(to)+len(pathParts)-len(from))
+	return append(append(renamed, to...), pathParts[len(from):]...)
+}
+
+// hasPathPrefix reports whether the leading segments of pathParts equal
+// prefix.
+func hasPathPrefix(pathParts, prefix tagPathParts) bool {
+	if len(prefix) > len(pathParts) {
+		return false
+	}
+	for i, segment := range prefix {
+		if pathParts[i] != segment {
+			return false
+		}
+	}
+	return true
+}
+
+// failedSegment returns the first segment of pathParts at which navigation
+// from srcVal stops resolving, by resolving ever longer leading parts of the
+// path. Source methods along the path are therefore called again.
+func failedSegment(cfg *config, srcVal reflect.Value, pathParts tagPathParts, runes bool) string {
+	for i, part := range pathParts {
+		if part == projectSegment || (i == len(pathParts)-1 && pathParts.IsGather()) {
+			return part // Not navigable on its own
+		}
+		value, err := lookUpField(cfg, srcVal, pathParts[:i+1], nil, runes)
+		if err != nil || !value.IsValid() {
+			return part
+		}
+	}
+	return pathParts[len(pathParts)-1]
+}
+
+// prefixedPath prepends the segments of the prefix registered with
+// WithPathPrefix to pathParts.
+func prefixedPath(cfg *config, pathParts tagPathParts, sep string) tagPathParts {
+	if cfg.pathPrefix == "" {
+		return pathParts
+	}
+	prefix := splitPath(cfg.pathPrefix, sep)
+	prefixed := make(tagPathParts, 0, len(prefix)+len(pathParts))
+	return append(append(prefixed, prefix...), pathParts...)
+}
+
+// resolvePath resolves a single tag path, projecting into dstType when the
+// path holds a projection segment and gathering into dstType when the path
+// ends with a wildcard or pick segment.
+func resolvePath(cfg *config, srcVal reflect.Value, dstType reflect.Type, pathParts tagPathParts, runes bool) (reflect.Value, error) {
+	if cfg.stats != nil {
+		cfg.stats.PathAttempts.Add(1)
+	}
+	if i := pathParts.ProjectionIndex(); i >= 0 {
+		container := srcVal
+		if parentParts := pathParts[:i]; !parentParts.IsEmpty() {
+			var err error
+			container, err = lookUpField(cfg, srcVal, parentParts, nil, runes)
+			if err != nil || !container.IsValid() {
+				return container, err
+			}
+		}
+		return projectElement(cfg, dstType, container, pathParts[i+1:], runes)
+	}
+
+	if !pathParts.IsGather() {
+		return lookUpField(cfg, srcVal, pathParts, leafPtrType(dstType), runes)
+	}
+
+	container := srcVal
+	if parentParts := pathParts[:len(pathParts)-1]; !parentParts.IsEmpty() {
+		var err error
+		container, err = lookUpField(cfg, srcVal, parentParts, nil, runes)
+		if err != nil || !container.IsValid() {
+			return container, err
+		}
+	}
+	return gatherElement(cfg, dstType, container, pathParts.GatherNames(cfg.tagSplitter.withDefaults().Options))
+}
+
+// leafPtrType returns dstType when it is a pointer type, which source pointers
+// are then assigned as-is, or nil otherwise.
+func leafPtrType(dstType reflect.Type) reflect.Type {
+	if dstType != nil && dstType.Kind() == reflect.Ptr {
+		return dstType
+	}
+	return nil
+}
+
+// isZeroLeaf checks if value is zero, looking through a non-nil pointer kept
+// as-is for a pointer destination of dstType.
+func isZeroLeaf(value reflect.Value, dstType reflect.Type) bool {
+	if value.Kind() == reflect.Ptr && value.Type() == dstType && !value.IsNil() {
+		return value.Elem().IsZero()
+	}
+	return value.IsZero()
+}
+
+// isNil checks if v is a nil pointer, interface, map, slice, func, or chan.
+// Values of other kinds are never nil.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// hydrates reports whether string sources are hydrated into dstType: with the
+// "hydrate" option, and with WithDefaultHydrate for destinations that are
+// neither strings nor unregistered interfaces unless "nohydrate" is set. Empty
+// interface destinations are never hydrated unless registered (see
+// isPassthrough).
+func hydrates(cfg *config, tag *sTag, dstType reflect.Type) bool {
+	if isPassthrough(cfg, dstType) {
+		return false // Strings are boxed as-is
+	}
+	if tag.HasHydrate() {
+		return true
+	}
+	if !cfg.defaultHydrate || tag.HasNoHydrate() || dstType.Kind() == reflect.String {
+		return false
+	}
+	if dstType.Kind() == reflect.Interface {
+		_, ok := cfg.interfaceTargets[dstType]
+		return ok
+	}
+	return true
+}
 
-	"github.com/daved/vtypes"
+var (
+	urlType       = reflect.TypeOf(url.URL{})
+	regexpPtrType = reflect.TypeOf((*regexp.Regexp)(nil))
 )
 
-// Merge merges values from src into dst based on dst's smap struct tags.
-func Merge(dst, src interface{}) error {
-	dstVal, err := makeDstValue(dst)
-	if err != nil {
-		return err
+// hydratedElement hydrates a string value into the destination type. Interface
+// destinations are hydrated through the concrete type registered for them
+// with WithInterfaceTarget. A Converter or Parser registered for the
+// destination type is tried before any built-in hydration, which remains the
+// fallback when it fails.
+func hydratedElement(cfg *config, tag *sTag, dstType reflect.Type, srcString string) (reflect.Value, error) {
+	if dstType.Kind() == reflect.Interface {
+		targetType, ok := cfg.interfaceTargets[dstType]
+		if !ok {
+			return reflect.Value{}, ErrInterfaceTargetMissing
+		}
+		if !targetType.Implements(dstType) {
+			return reflect.Value{}, ErrFieldTypesIncompatible
+		}
+		return hydratedElement(cfg, tag, targetType, srcString)
 	}
 
-	srcVal, err := makeSrcValue(src)
-	if err != nil {
-		return err
+	registered, ok, regErr := registeredElement(dstType, srcString)
+	if ok && regErr == nil {
+		return registered, nil
+	}
+	hydrated, err := builtinElement(cfg, tag, dstType, srcString)
+	if err != nil && regErr != nil {
+		return reflect.Value{}, errors.Join(regErr, err)
+	}
+	return hydrated, err
+}
+
+// builtinElement hydrates a string value into the destination type without
+// registered Converters or Parsers. URL destinations are parsed with url.Parse,
+// *regexp.Regexp destinations are compiled (with POSIX syntax when the tag
+// sets "posix"), bool destinations accept the tokens of boolTokens, and
+// complex destinations are parsed with strconv.ParseComplex. Other
+// destinations go through the hydration chain of chainedElement.
+func builtinElement(cfg *config, tag *sTag, dstType reflect.Type, srcString string) (reflect.Value, error) {
+	switch dstType {
+	case urlType, reflect.PtrTo(urlType):
+		u, err := url.Parse(srcString)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if dstType.Kind() == reflect.Ptr {
+			return reflect.ValueOf(u), nil
+		}
+		return reflect.ValueOf(*u), nil
+	case regexpPtrType:
+		compile := regexp.Compile
+		if tag.HasPOSIX() {
+			compile = regexp.CompilePOSIX
+		}
+		re, err := compile(srcString)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(re), nil
+	}
+
+	switch dstType.Kind() {
+	case reflect.Bool:
+		b, err := boolElement(srcString)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b).Convert(dstType), nil
+	case reflect.Ptr:
+		if isBoolType(dstType) {
+			b, err := hydratedElement(cfg, tag, dstType.Elem(), srcString)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return pointerElement(dstType, b), nil
+		}
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(srcString, dstType.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(c).Convert(dstType), nil
 	}
 
-	return mergeFields(dstVal, srcVal)
+	return chainedElement(dstType, srcString)
 }
 
-// makeDstValue ensures dst is a non-nil pointer to a struct and returns its value.
-func makeDstValue(dst interface{}) (reflect.Value, error) {
-	dstVal := reflect.ValueOf(dst)
-	if dstVal.Kind() != reflect.Ptr || dstVal.IsNil() {
-		return reflect.Value{}, ErrDstInvalid
+// isLosslessConversion checks if srcType can be converted to dstType without
+// loss, which is the case for distinct named types sharing the same basic
+// kind (e.g. string and "type ID string").
+func isLosslessConversion(srcType, dstType reflect.Type) bool {
+	if srcType == dstType || srcType.Kind() != dstType.Kind() {
+		return false
 	}
-	dstVal = dstVal.Elem()
-	if dstVal.Kind() != reflect.Struct {
-		return reflect.Value{}, ErrDstInvalid
+	switch srcType.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return srcType.ConvertibleTo(dstType)
 	}
-	return dstVal, nil
+	return false
 }
 
-// makeSrcValue ensures src is a struct or non-nil pointer to a struct and returns its value.
-func makeSrcValue(src interface{}) (reflect.Value, error) {
-	srcVal := reflect.ValueOf(src)
-	if srcVal.Kind() == reflect.Ptr {
-		if srcVal.IsNil() {
-			return reflect.Value{}, ErrSrcInvalid
-		}
-		srcVal = srcVal.Elem()
+// pointerElement wraps a value in a newly allocated pointer when the pointer
+// destination type's element type can hold it (e.g. int into *int). Other
+// values are returned unchanged.
+func pointerElement(dstType reflect.Type, srcVal reflect.Value) reflect.Value {
+	if srcVal.Type().AssignableTo(dstType) {
+		return srcVal
 	}
-	if srcVal.Kind() != reflect.Struct {
-		return reflect.Value{}, ErrSrcInvalid
+	elemType := dstType.Elem()
+	if isLosslessConversion(srcVal.Type(), elemType) {
+		srcVal = srcVal.Convert(elemType)
 	}
-	return srcVal, nil
+	if !srcVal.Type().AssignableTo(elemType) {
+		return srcVal
+	}
+	ptr := reflect.New(elemType)
+	ptr.Elem().Set(srcVal)
+	return ptr
 }
 
-// mergeFields applies the smap tag mappings from srcVal to dstVal.
-func mergeFields(dstVal, srcVal reflect.Value) error {
-	dstType := dstVal.Type()
-	for i := 0; i < dstType.NumField(); i++ {
-		field := dstType.Field(i)
-		rawTag, ok := field.Tag.Lookup(TagKey)
-		if !ok {
-			continue
-		}
-		tag, err := newSTag(rawTag)
-		if err != nil {
-			return err
-		}
-		if err := mergeField(dstVal.Field(i), srcVal, tag); err != nil {
-			return err
+// isByteSlice checks if typ is a slice of bytes (e.g. []byte).
+func isByteSlice(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+}
+
+var (
+	byteSliceType         = reflect.TypeOf([]byte(nil))
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// isBinaryUnmarshaler checks if typ, or a pointer to typ, implements
+// encoding.BinaryUnmarshaler. Interface types are excluded.
+func isBinaryUnmarshaler(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Interface {
+		return false
+	}
+	return typ.Implements(binaryUnmarshalerType) || reflect.PtrTo(typ).Implements(binaryUnmarshalerType)
+}
+
+// binaryElement unmarshals b into a new value of the destination type using
+// its UnmarshalBinary method. Pointer destinations are allocated.
+func binaryElement(dstType reflect.Type, b []byte) (reflect.Value, error) {
+	if dstType.Kind() == reflect.Ptr && dstType.Implements(binaryUnmarshalerType) {
+		ptr := reflect.New(dstType.Elem())
+		if err := ptr.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b); err != nil {
+			return reflect.Value{}, err
 		}
+		return ptr, nil
 	}
-	return nil
+	ptr := reflect.New(dstType)
+	if err := ptr.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b); err != nil {
+		return reflect.Value{}, err
+	}
+	return ptr.Elem(), nil
 }
 
-// mergeField sets dstField based on the smap tag paths in srcVal.
-func mergeField(dstField, srcVal reflect.Value, tag *sTag) error {
-	if tag.IsEmpty() {
-		return NewMergeFieldError(ErrTagEmpty, "", dstField.Type().String(), "")
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isPassthrough reports whether resolved values are boxed into dstType without
+// hydration: an empty interface destination accepts any value, so strings are
+// not hydrated unless WithInterfaceTarget registers a concrete type for it.
+// Explicit conversion options (e.g. "json" or "trim") still apply.
+func isPassthrough(cfg *config, dstType reflect.Type) bool {
+	if dstType.Kind() != reflect.Interface || dstType.NumMethod() != 0 {
+		return false
 	}
+	_, ok := cfg.interfaceTargets[dstType]
+	return !ok
+}
 
-	finalValue, err := findLeafValueByPathsParts(srcVal, tag)
-	if err != nil {
-		return NewMergeFieldError(err, tag.String(), dstField.Type().String(), "")
+// isScanner checks if typ, or a pointer to typ, implements sql.Scanner (e.g.
+// sql.NullString).
+func isScanner(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Interface {
+		return false
 	}
+	return typ.Implements(scannerType) || reflect.PtrTo(typ).Implements(scannerType)
+}
 
-	if !finalValue.IsValid() {
-		if dstField.Kind() == reflect.Ptr {
-			return nil
+// scannerElement scans srcVal into a new value of the destination type using
+// its Scan method, which marks sql.Null* types valid. Pointer destinations are
+// allocated.
+func scannerElement(dstType reflect.Type, srcVal reflect.Value) (reflect.Value, error) {
+	if dstType.Kind() == reflect.Ptr && dstType.Implements(scannerType) {
+		ptr := reflect.New(dstType.Elem())
+		if err := ptr.Interface().(sql.Scanner).Scan(srcVal.Interface()); err != nil {
+			return reflect.Value{}, err
 		}
-		return nil
+		return ptr, nil
+	}
+	ptr := reflect.New(dstType)
+	if err := ptr.Interface().(sql.Scanner).Scan(srcVal.Interface()); err != nil {
+		return reflect.Value{}, err
 	}
+	return ptr.Elem(), nil
+}
 
-	if tag.HasHydrate() && finalValue.Kind() == reflect.String {
-		hydratedValue, err := hydratedElement(dstField.Type(), finalValue.String())
-		if err != nil {
-			return NewMergeFieldError(err, tag.String(), dstField.Type().String(), finalValue.Type().String())
+// base64Element decodes a base64 string value into the byte slice destination
+// type, using the URL-safe alphabet when urlSafe is set.
+func base64Element(dstType reflect.Type, srcString string, urlSafe bool) (reflect.Value, error) {
+	enc := base64.StdEncoding
+	if urlSafe {
+		enc = base64.URLEncoding
+	}
+	b, err := enc.DecodeString(srcString)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(b).Convert(dstType), nil
+}
+
+// transformedElement applies transform to a string value, or to each string
+// held by a gathered or projected map or slice (at any depth), returning a
+// copy so the source is untouched. Other values, including []byte, are
+// returned unchanged.
+func transformedElement(v reflect.Value, transform func(string) string) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(transform(v.String())).Convert(v.Type())
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
 		}
-		finalValue = hydratedValue
+		return transformedElement(v.Elem(), transform)
+	case reflect.Slice:
+		if v.IsNil() || isByteSlice(v.Type()) {
+			return v
+		}
+		transformed := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			transformed.Index(i).Set(transformedElement(v.Index(i), transform))
+		}
+		return transformed
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		transformed := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			transformed.SetMapIndex(iter.Key(), transformedElement(iter.Value(), transform))
+		}
+		return transformed
 	}
+	return v
+}
 
-	if !finalValue.Type().AssignableTo(dstField.Type()) {
-		return NewMergeFieldError(ErrFieldTypesIncompatible, tag.String(), dstField.Type().String(), finalValue.Type().String())
+// stringerElement converts a value implementing fmt.Stringer, or else error,
+// into its string form. Other values are returned unchanged.
+func stringerElement(srcVal reflect.Value) reflect.Value {
+	if !srcVal.CanInterface() || isNil(srcVal) {
+		return srcVal
 	}
-	dstField.Set(finalValue)
-	return nil
+	switch v := srcVal.Interface().(type) {
+	case fmt.Stringer:
+		return reflect.ValueOf(v.String())
+	case error:
+		return reflect.ValueOf(v.Error())
+	}
+	return srcVal
 }
 
-// findLeafValueByPathsParts finds the last valid, non-zero leaf value from the given paths.
-func findLeafValueByPathsParts(srcVal reflect.Value, tag *sTag) (reflect.Value, error) {
-	var finalValue reflect.Value
-	for _, pathParts := range tag.pathsParts {
-		value, err := lookUpField(srcVal, pathParts)
+// jsonElement decodes a JSON-encoded string value into the destination type.
+func jsonElement(dstType reflect.Type, srcString string) (reflect.Value, error) {
+	decodedPtr := reflect.New(dstType)
+	if err := json.Unmarshal([]byte(srcString), decodedPtr.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return decodedPtr.Elem(), nil
+}
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// jsonNumberElement converts a json.Number into the numeric destination type
+// (or the numeric type a pointer destination points to). Numbers that are not
+// integers, or that overflow the destination, are errors. Non-numeric
+// destinations receive the number unchanged.
+func jsonNumberElement(dstType reflect.Type, n json.Number) (reflect.Value, error) {
+	numType := dstType
+	if numType.Kind() == reflect.Ptr {
+		numType = numType.Elem()
+	}
+
+	numValue := reflect.New(numType).Elem()
+	switch numType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := n.Int64()
 		if err != nil {
-			if errors.Is(err, errKeepLooking) {
-				continue
-			}
 			return reflect.Value{}, err
 		}
-		if value.IsValid() {
-			if tag.HasSkipZero() && value.IsZero() {
-				continue
-			}
-			finalValue = value
+		if numValue.OverflowInt(i) {
+			return reflect.Value{}, ErrNumberOverflow
+		}
+		numValue.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if numValue.OverflowUint(u) {
+			return reflect.Value{}, ErrNumberOverflow
+		}
+		numValue.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := n.Float64()
+		if err != nil {
+			return reflect.Value{}, err
 		}
+		if numValue.OverflowFloat(f) {
+			return reflect.Value{}, ErrNumberOverflow
+		}
+		numValue.SetFloat(f)
+	default:
+		return reflect.ValueOf(n), nil
 	}
-	return finalValue, nil
+	return numValue, nil
 }
 
-// hydratedElement hydrates a string value into the destination type.
-func hydratedElement(dstType reflect.Type, srcString string) (reflect.Value, error) {
-	hydratedPtr := reflect.New(dstType)
-	hydrated := hydratedPtr.Interface()
-	if err := vtypes.Hydrate(hydrated, srcString); err != nil {
-		return reflect.Value{}, err
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// durationUnits maps "unit" option values to durations.
+var durationUnits = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// durationElement converts an integer value counting the given unit (default
+// nanoseconds) into a time.Duration. Non-integer values are returned
+// unchanged.
+func durationElement(srcVal reflect.Value, unit string) (reflect.Value, error) {
+	var n int64
+	switch srcVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = srcVal.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := srcVal.Uint()
+		if u > math.MaxInt64 {
+			return reflect.Value{}, ErrNumberOverflow
+		}
+		n = int64(u)
+	default:
+		return srcVal, nil
+	}
+
+	scale, ok := durationUnits[unit]
+	if !ok {
+		return reflect.Value{}, ErrDurationUnitInvalid
+	}
+	if n > math.MaxInt64/int64(scale) || n < math.MinInt64/int64(scale) {
+		return reflect.Value{}, ErrNumberOverflow
+	}
+	return reflect.ValueOf(time.Duration(n) * scale), nil
+}
+
+// boolTokens maps the accepted bool tokens, in lowercase, to their values.
+var boolTokens = map[string]bool{
+	"1": true, "t": true, "true": true, "yes": true, "on": true,
+	"0": false, "f": false, "false": false, "no": false, "off": false,
+}
+
+// boolElement parses a bool token (see boolTokens), ignoring case and
+// surrounding whitespace.
+func boolElement(srcString string) (bool, error) {
+	b, ok := boolTokens[strings.ToLower(strings.TrimSpace(srcString))]
+	if !ok {
+		return false, fmt.Errorf("%w: %q", ErrBoolInvalid, srcString)
+	}
+	return b, nil
+}
+
+// maxHydrateInput is the number of runes of a raw string kept in hydrate
+// errors (see WithHydrateErrorWrap).
+const maxHydrateInput = 64
+
+// hydrateInputError readies err, from hydrating raw, for reporting. With
+// WithRedactHydrateInput, the message is replaced entirely, since err's own
+// message may quote raw in any form. Otherwise, with WithHydrateErrorWrap,
+// raw is prepended, truncated to maxHydrateInput runes. The original error
+// stays reachable through Unwrap.
+func hydrateInputError(cfg *config, raw string, err error) error {
+	if cfg.redactHydrateInput {
+		return &hydrateInputErr{msg: "hydrate [redacted] failed", err: err}
+	}
+	if !cfg.hydrateErrorWrap {
+		return err
+	}
+	shown := raw
+	if runes := []rune(raw); len(runes) > maxHydrateInput {
+		shown = string(runes[:maxHydrateInput]) + "..."
+	}
+	msg := err.Error()
+	if shown != raw {
+		msg = strings.ReplaceAll(msg, raw, shown) // Keep long inputs short
+	}
+	return &hydrateInputErr{msg: "hydrate " + strconv.Quote(shown) + ": " + msg, err: err}
+}
+
+// hydrateInputErr carries a hydrate error whose message has been rewritten by
+// hydrateInputError, while still unwrapping to the original error.
+type hydrateInputErr struct {
+	msg string
+	err error
+}
+
+func (e *hydrateInputErr) Error() string { return e.msg }
+
+func (e *hydrateInputErr) Unwrap() error { return e.err }
+
+// isBoolType checks if typ is a bool type or a pointer to one (e.g. *bool).
+func isBoolType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Bool
+}
+
+// isInteger checks if kind is a signed or unsigned integer kind.
+func isInteger(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// isNumber checks if kind is an integer or float kind.
+func isNumber(kind reflect.Kind) bool {
+	return isInteger(kind) || kind == reflect.Float32 || kind == reflect.Float64
+}
+
+// isRenumbered checks if a number of srcType must be hydrated from its string
+// form to become the numeric destination type, because their kinds differ
+// (e.g. int into int64, or float64 into int, as held by []interface{} rows).
+// Durations are converted with their unit instead.
+func isRenumbered(srcType, dstType reflect.Type) bool {
+	if dstType.Kind() == reflect.Ptr {
+		dstType = dstType.Elem()
+	}
+	if dstType == durationType {
+		return false
+	}
+	return isNumber(srcType.Kind()) && isNumber(dstType.Kind()) && srcType.Kind() != dstType.Kind()
+}
+
+// numberString formats the number v in decimal without an exponent, so that
+// whole floats parse as integers.
+func numberString(v reflect.Value) string {
+	switch {
+	case isInteger(v.Kind()) && v.CanInt():
+		return strconv.FormatInt(v.Int(), 10)
+	case isInteger(v.Kind()):
+		return strconv.FormatUint(v.Uint(), 10)
+	default:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
 	}
-	return hydratedPtr.Elem(), nil
 }
 
-// lookUpField navigates srcVal using the path parts and returns the value.
-func lookUpField(srcVal reflect.Value, pathParts tagPathParts) (reflect.Value, error) {
+// intBoolElement converts an integer value into the bool destination type (0
+// is false, non-zero is true). When strict, only 0 and 1 are accepted.
+// Non-integer values are returned unchanged.
+func intBoolElement(dstType reflect.Type, srcVal reflect.Value, strict bool) (reflect.Value, error) {
+	var isZero, isOne bool
+	switch srcVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		isZero, isOne = srcVal.Int() == 0, srcVal.Int() == 1
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		isZero, isOne = srcVal.Uint() == 0, srcVal.Uint() == 1
+	default:
+		return srcVal, nil
+	}
+	if strict && !isZero && !isOne {
+		return reflect.Value{}, ErrIntBoolInvalid
+	}
+	return reflect.ValueOf(!isZero).Convert(dstType), nil
+}
+
+// lookUpField navigates srcVal using the path parts and returns the value. A
+// final numeric segment indexes into a string, yielding the byte at that
+// position, or the rune when runes is set. The final value is dereferenced
+// through pointers, except that a pointer of leafType is returned as-is.
+func lookUpField(cfg *config, srcVal reflect.Value, pathParts tagPathParts, leafType reflect.Type, runes bool) (reflect.Value, error) {
 	if pathParts.IsEmpty() {
 		return reflect.Value{}, ErrTagPathEmpty
 	}
@@ -145,6 +1701,9 @@ func lookUpField(srcVal reflect.Value, pathParts tagPathParts) (reflect.Value, e
 	current := srcVal
 	for i, part := range pathParts {
 		value := current
+		if value.Kind() == reflect.Interface {
+			value = value.Elem() // Decoded documents hold interface values
+		}
 		if value.Kind() == reflect.Ptr && value.IsNil() {
 			return reflect.Value{}, errKeepLooking // Unset, try next path
 		}
@@ -156,7 +1715,7 @@ func lookUpField(srcVal reflect.Value, pathParts tagPathParts) (reflect.Value, e
 		switch value.Kind() {
 		case reflect.Struct:
 			var err error
-			current, err = lookupStructFieldOrMethod(value, current, part, isLastPart)
+			current, err = lookupStructFieldOrMethod(cfg, value, current, part, leafType, isLastPart)
 			if err != nil {
 				return reflect.Value{}, err
 			}
@@ -166,14 +1725,14 @@ func lookUpField(srcVal reflect.Value, pathParts tagPathParts) (reflect.Value, e
 			if current.IsValid() {
 				continue
 			}
-			if isLastPart {
+			if isLastPart && cfg.pathNotFound == PathNotFoundError {
 				return reflect.Value{}, ErrTagPathNotFound
 			}
 			return reflect.Value{}, errKeepLooking
 
 		case reflect.Map:
 			var err error
-			current, err = lookupMapValue(value, part, isLastPart)
+			current, err = lookupMapValue(value, part, leafType, isLastPart)
 			if err != nil {
 				return reflect.Value{}, err
 			}
@@ -186,7 +1745,7 @@ func lookUpField(srcVal reflect.Value, pathParts tagPathParts) (reflect.Value, e
 
 		case reflect.Slice, reflect.Array:
 			var err error
-			current, err = lookupSliceOrArrayElement(value, part, isLastPart)
+			current, err = lookupSliceOrArrayElement(value, part, leafType, isLastPart)
 			if err != nil {
 				return reflect.Value{}, err
 			}
@@ -198,6 +1757,15 @@ func lookUpField(srcVal reflect.Value, pathParts tagPathParts) (reflect.Value, e
 			}
 			return reflect.Value{}, errKeepLooking
 
+		case reflect.String:
+			if !isLastPart {
+				return reflect.Value{}, errKeepLooking
+			}
+			if current = lookupStringElement(value, part, runes); current.IsValid() {
+				return current, nil
+			}
+			return reflect.Value{}, errKeepLooking
+
 		default:
 			return reflect.Value{}, errKeepLooking
 		}
@@ -206,59 +1774,207 @@ func lookUpField(srcVal reflect.Value, pathParts tagPathParts) (reflect.Value, e
 	return reflect.Value{}, ErrTagPathNotFound
 }
 
+// lookupStringElement handles string index lookup, by byte or by rune.
+func lookupStringElement(value reflect.Value, part string, runes bool) reflect.Value {
+	idx, err := strconv.Atoi(part)
+	if err != nil || idx < 0 {
+		return reflect.Value{}
+	}
+	if !runes {
+		if idx >= value.Len() {
+			return reflect.Value{}
+		}
+		return value.Index(idx)
+	}
+	rs := []rune(value.String())
+	if idx >= len(rs) {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(rs[idx])
+}
+
 // lookupStructFieldOrMethod handles struct field or method lookup.
-func lookupStructFieldOrMethod(value, current reflect.Value, part string, isLastPart bool) (reflect.Value, error) {
-	field := value.FieldByName(part)
+func lookupStructFieldOrMethod(cfg *config, value, current reflect.Value, part string, leafType reflect.Type, isLastPart bool) (reflect.Value, error) {
+	if cfg.preferMethod {
+		if method := current.MethodByName(part); method.IsValid() {
+			return structMethodElement(cfg, value, method, part)
+		}
+	}
 	typ := value.Type()
-	if f, ok := typ.FieldByName(part); ok && field.IsValid() && f.PkgPath == "" {
+	if f, ok := typ.FieldByName(part); ok && f.PkgPath == "" {
+		// Exported fields promoted from unexported embedded structs are
+		// reachable; a nil embedded pointer leaves nothing to read.
+		field, err := value.FieldByIndexErr(f.Index)
+		if err != nil {
+			return reflect.Value{}, errKeepLooking
+		}
 		if field.Kind() == reflect.Ptr && field.IsNil() {
 			return reflect.Value{}, errKeepLooking
 		}
 		current = field
 		if isLastPart {
-			for current.Kind() == reflect.Ptr && !current.IsNil() {
-				current = current.Elem()
-			}
+			current = leafElement(current, leafType, false)
 		}
 		return current, nil
 	}
 	// Try method on original (possibly pointer) value
-	method := current.MethodByName(part)
-	if method.IsValid() && method.Type().NumIn() == 0 {
-		results := method.Call(nil)
-		switch len(results) {
-		case 1:
-			return results[0], nil
-		case 2:
-			if err, ok := results[1].Interface().(error); ok {
-				if err != nil {
-					return reflect.Value{}, err
-				}
-				return results[0], nil
+	if method := current.MethodByName(part); method.IsValid() {
+		return structMethodElement(cfg, value, method, part)
+	}
+	if cfg.getterFallback {
+		if method := current.MethodByName("Get" + part); method.IsValid() {
+			return structMethodElement(cfg, value, method, "Get"+part)
+		}
+	}
+	return reflect.Value{}, nil
+}
+
+// structMethodElement calls the named method of the struct value, unless it
+// is promoted from a nil embedded value.
+func structMethodElement(cfg *config, value, method reflect.Value, name string) (reflect.Value, error) {
+	if promotedFromNil(value, name) {
+		return reflect.Value{}, errKeepLooking
+	}
+	if cfg.stats != nil {
+		cfg.stats.MethodCalls.Add(1)
+	}
+	return callMethod(method)
+}
+
+// promotedFromNil reports whether the named method of the struct value is
+// promoted from an embedded interface or pointer that is nil, in which case
+// calling it would panic. A method the struct declares itself shadows any
+// embedded method of the same name and is never promoted.
+func promotedFromNil(value reflect.Value, name string) bool {
+	typ := value.Type()
+	if declaresMethod(typ, name) {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.Anonymous || !hasMethod(field.Type, name) {
+			continue
+		}
+		embedded := value.Field(i)
+		for embedded.Kind() == reflect.Ptr || embedded.Kind() == reflect.Interface {
+			if embedded.IsNil() {
+				return true
 			}
+			embedded = embedded.Elem()
+		}
+		if embedded.Kind() == reflect.Struct {
+			return promotedFromNil(embedded, name)
+		}
+		return false
+	}
+	return false
+}
+
+// declaresMethod checks if the struct typ, or a pointer to typ, declares the
+// named method itself rather than promoting it from an embedded field. When no
+// embedded field has the method, it can only be declared. Otherwise,
+// reflection cannot tell the two apart, and isPromotedWrapper is consulted.
+func declaresMethod(typ reflect.Type, name string) bool {
+	if !hasMethod(typ, name) {
+		return false
+	}
+	embedded := false
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Anonymous && hasMethod(field.Type, name) {
+			embedded = true
+		}
+	}
+	if !embedded {
+		return true
+	}
+	for _, t := range []reflect.Type{typ, reflect.PtrTo(typ)} {
+		if method, ok := t.MethodByName(name); ok && !isPromotedWrapper(method.Func) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPromotedWrapper checks if fn, a method func obtained through reflection,
+// is a wrapper generated by the compiler, as for methods promoted from an
+// embedded field and pointer methods wrapping value methods. The gc toolchain
+// attributes such wrappers to "<autogenerated>" rather than a source file;
+// this is not guaranteed elsewhere, so a wrapper reported otherwise is
+// treated as declared.
+func isPromotedWrapper(fn reflect.Value) bool {
+	pc := fn.Pointer()
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return false
+	}
+	file, _ := f.FileLine(pc)
+	return file == "<autogenerated>"
+}
+
+// hasMethod checks if typ, or a pointer to a non-interface typ, has the named
+// method.
+func hasMethod(typ reflect.Type, name string) bool {
+	if _, ok := typ.MethodByName(name); ok {
+		return true
+	}
+	if typ.Kind() == reflect.Interface || typ.Kind() == reflect.Ptr {
+		return false
+	}
+	_, ok := reflect.PtrTo(typ).MethodByName(name)
+	return ok
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// callMethod calls a zero-argument method returning either a value or a value
+// and an error. A method returning only an error acts as a presence check: a
+// non-nil error aborts, while a nil error resolves to no value so that the
+// next path is tried. Methods with other signatures resolve to no value.
+func callMethod(method reflect.Value) (reflect.Value, error) {
+	methodType := method.Type()
+	if methodType.NumIn() != 0 {
+		return reflect.Value{}, nil
+	}
+	switch methodType.NumOut() {
+	case 1:
+		result := method.Call(nil)[0]
+		if methodType.Out(0) != errorType {
+			return result, nil
 		}
+		if err, _ := result.Interface().(error); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.Value{}, errKeepLooking
+	case 2:
+		if methodType.Out(1) != errorType {
+			return reflect.Value{}, nil
+		}
+		results := method.Call(nil)
+		if err, _ := results[1].Interface().(error); err != nil {
+			return reflect.Value{}, err
+		}
+		return results[0], nil
 	}
 	return reflect.Value{}, nil
 }
 
-// lookupMapValue handles map key lookup with type conversion.
-func lookupMapValue(value reflect.Value, part string, isLastPart bool) (reflect.Value, error) {
-	keyType := value.Type().Key()
+// mapKeyElement converts a path segment into a key of the map key type.
+func mapKeyElement(keyType reflect.Type, part string) (reflect.Value, error) {
 	var key reflect.Value
 	// Try converting part to the map's key type
 	switch keyType.Kind() {
 	case reflect.String:
-		key = reflect.ValueOf(part)
+		key = reflect.ValueOf(part).Convert(keyType)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if n, err := strconv.ParseInt(part, 10, 64); err == nil {
+		if n, err := strconv.ParseInt(part, 10, keyType.Bits()); err == nil {
 			key = reflect.ValueOf(n).Convert(keyType)
 		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if n, err := strconv.ParseUint(part, 10, 64); err == nil {
+		if n, err := strconv.ParseUint(part, 10, keyType.Bits()); err == nil {
 			key = reflect.ValueOf(n).Convert(keyType)
 		}
 	case reflect.Float32, reflect.Float64:
-		if f, err := strconv.ParseFloat(part, 64); err == nil {
+		if f, err := strconv.ParseFloat(part, keyType.Bits()); err == nil {
 			key = reflect.ValueOf(f).Convert(keyType)
 		}
 	default:
@@ -267,29 +1983,66 @@ func lookupMapValue(value reflect.Value, part string, isLastPart bool) (reflect.
 	if !key.IsValid() {
 		return reflect.Value{}, ErrTagPathInvalidKeyType
 	}
+	return key, nil
+}
+
+// lookupMapValue handles map key lookup with type conversion.
+func lookupMapValue(value reflect.Value, part string, leafType reflect.Type, isLastPart bool) (reflect.Value, error) {
+	key, err := mapKeyElement(value.Type().Key(), part)
+	if err != nil {
+		return reflect.Value{}, err
+	}
 	field := value.MapIndex(key)
 	if !field.IsValid() {
 		return reflect.Value{}, nil
 	}
 	current := field
 	if isLastPart {
-		for current.Kind() == reflect.Ptr && !current.IsNil() {
-			current = current.Elem()
-		}
+		current = leafElement(current, leafType, true)
 	}
 	return current, nil
 }
 
-// lookupSliceOrArrayElement handles slice or array index lookup.
-func lookupSliceOrArrayElement(value reflect.Value, part string, isLastPart bool) (reflect.Value, error) {
-	if idx, err := strconv.Atoi(part); err == nil && idx >= 0 && idx < value.Len() {
+// lookupSliceOrArrayElement handles slice or array index lookup, including the
+// "first" and "last" keywords.
+func lookupSliceOrArrayElement(value reflect.Value, part string, leafType reflect.Type, isLastPart bool) (reflect.Value, error) {
+	if idx, ok := sliceIndex(part, value.Len()); ok {
 		current := value.Index(idx)
 		if isLastPart {
-			for current.Kind() == reflect.Ptr && !current.IsNil() {
-				current = current.Elem()
-			}
+			current = leafElement(current, leafType, true)
 		}
 		return current, nil
 	}
 	return reflect.Value{}, nil
 }
+
+// sliceIndex parses a slice segment into an index within length n. The
+// keywords "first" and "last" are parsed before any number, so they stand for
+// 0 and n-1; an empty slice has neither.
+func sliceIndex(part string, n int) (int, bool) {
+	idx := -1
+	switch part {
+	case "first":
+		idx = 0
+	case "last":
+		idx = n - 1
+	default:
+		if i, err := strconv.Atoi(part); err == nil {
+			idx = i
+		}
+	}
+	return idx, idx >= 0 && idx < n
+}
+
+// leafElement dereferences the final value of a path through non-nil pointers,
+// and through interfaces when interfaces is set. A pointer of leafType is not
+// dereferenced, so pointer destinations receive source pointers as-is.
+func leafElement(current reflect.Value, leafType reflect.Type, interfaces bool) reflect.Value {
+	for (current.Kind() == reflect.Ptr || (interfaces && current.Kind() == reflect.Interface)) && !current.IsNil() {
+		if current.Type() == leafType {
+			break
+		}
+		current = current.Elem()
+	}
+	return current
+}
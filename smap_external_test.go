@@ -1,9 +1,24 @@
 package smap_test
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
 	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/daved/smap"
 )
@@ -49,10 +64,353 @@ type ConfigSkipZero struct {
 	Count int `smap:"EV.Count|FV.Count,skipzero"`
 }
 
+type ConfigSkipZeroMethod struct {
+	Count int `smap:"EV.GetCount,skipzero"`
+}
+
+type ConfigZeroAsAbsent struct {
+	Count int    `smap:"EV.Count|FV.Count"`
+	Value string `smap:"FV.Value|EV.Value,nozeroskip"`
+}
+
+type ConfigProjection struct {
+	Hosts []string `smap:"EV.Servers.#.Host"`
+	Ports []Port   `smap:"EV.Servers.#.Port"`
+}
+
+type ConfigProjectionMismatch struct {
+	Hosts []int `smap:"EV.Servers.#.Host"`
+}
+
+type ConfigComplex struct {
+	Impedance complex128 `smap:"EV.Value,hydrate"`
+}
+
+type ConfigComplex64 struct {
+	Impedance complex64 `smap:"EV.Value,hydrate"`
+}
+
+type ConfigPaths struct {
+	URL     string `smap:"EV.OldURL"`
+	FileURL string `smap:"FV.Legacy.URL"`
+}
+
+type ConfigErrorField struct {
+	Err error `smap:"EV.Err"`
+}
+
+type ConfigErrorString struct {
+	Msg string `smap:"EV.Err"`
+}
+
+type ConfigErrorStringer struct {
+	Msg string `smap:"EV.Err,stringer"`
+}
+
+type ConfigSkipTypes struct {
+	URL   url.URL `smap:"EV.AISvcURL,hydrate"`
+	Key   string  `smap:"EV.AISvcKey"`
+	Count int     `smap:"EV.Count"`
+}
+
+type ConfigDuration struct {
+	Timeout time.Duration `smap:"EV.Count,unit=ms"`
+	Raw     time.Duration `smap:"EV.Count"`
+	Parsed  time.Duration `smap:"EV.Value,hydrate,unit=ms"`
+}
+
+type ConfigDurationPtr struct {
+	Timeout *time.Duration `smap:"EV.Count,unit=s"`
+	Raw     *time.Duration `smap:"EV.Count"`
+}
+
+type ConfigDurationUnitInvalid struct {
+	Timeout time.Duration `smap:"EV.Count,unit=days"`
+}
+
+type ConfigDurationOverflow struct {
+	Timeout time.Duration `smap:"EV.Count,unit=h"`
+}
+
+type ConfigMissingPath struct {
+	URL string `smap:"EV.AISvcURL|EV.Missing"`
+}
+
+type ConfigTransform struct {
+	Key    string            `smap:"EV.AISvcKey,trim,lowercase"`
+	ID     ID                `smap:"EV.Value,trim"`
+	Labels map[string]string `smap:"EV.Labels.*,lowercase"`
+	Hosts  []string          `smap:"EV.Servers.#.Host,trim,lowercase"`
+}
+
+type ConfigStringIndex struct {
+	Initial byte `smap:"EV.Value.0"`
+	First   rune `smap:"EV.Value.0,rune"`
+	Missing rune `smap:"EV.Value.9,rune"`
+}
+
+type ConfigBinary struct {
+	Version    Version  `smap:"EV.Raw"`
+	VersionPtr *Version `smap:"EV.Raw"`
+	Encoded    Version  `smap:"EV.Value,base64"`
+}
+
+type Version struct {
+	Major, Minor byte
+}
+
+var errVersionLength = errors.New("version must be two bytes")
+
+func (v *Version) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errVersionLength
+	}
+	v.Major, v.Minor = data[0], data[1]
+	return nil
+}
+
+type ConfigSetter struct {
+	port int
+	Port struct{} `smap:"EV.Value,hydrate,setter=SetPort"`
+}
+
+var errPortInvalid = errors.New("port must be positive")
+
+func (c *ConfigSetter) SetPort(port int) error {
+	if port <= 0 {
+		return errPortInvalid
+	}
+	c.port = port
+	return nil
+}
+
+type ConfigSetters struct {
+	calls []string
+	Port  struct{} `smap:"EV.Value,hydrate,setter=SetPort"`
+	Key   struct{} `smap:"EV.AISvcKey,setter=SetKey"`
+	URL   string   `smap:"EV.AISvcURL"`
+}
+
+func (c *ConfigSetters) SetPort(port int) { c.calls = append(c.calls, fmt.Sprint(port)) }
+
+func (c *ConfigSetters) SetKey(key string) { c.calls = append(c.calls, key) }
+
+type ConfigSetterMissing struct {
+	Port struct{} `smap:"EV.Value,setter=SetMissing"`
+}
+
+type ConfigSetterSignature struct {
+	Port struct{} `smap:"EV.Value,setter=SetPort"`
+}
+
+func (c *ConfigSetterSignature) SetPort(host string, port int) {}
+
+type ConfigNullable struct {
+	Key   sql.NullString `smap:"EV.AISvcKey"`
+	Count sql.NullInt64  `smap:"EV.Count"`
+	Port  sql.NullInt32  `smap:"EV.Value"`
+	URL   sql.NullString `smap:"EV.AISvcURL,skipzero"`
+}
+
+type ConfigSizedInts struct {
+	Small int8  `smap:"EV.Value,hydrate"`
+	Byte  uint8 `smap:"EV.AISvcKey,hydrate"`
+}
+
+type ConfigDefaultHydrate struct {
+	Count   int           `smap:"EV.Value"`
+	Timeout time.Duration `smap:"EV.AISvcKey"`
+	URL     string        `smap:"EV.AISvcURL"`
+}
+
+type ConfigNoHydrate struct {
+	Raw int `smap:"EV.Value,nohydrate"`
+}
+
+type ConfigMethodPointer struct {
+	Host string `smap:"FV.Value|EV.Primary.Host"`
+}
+
+type ConfigStrictNamed struct {
+	ID ID `smap:"EV.AISvcKey"`
+}
+
+type ConfigStrictHydrate struct {
+	Count int `smap:"EV.Value,hydrate"`
+}
+
+type ConfigStrictPointer struct {
+	Key *string `smap:"EV.AISvcKey"`
+}
+
 type ConfigDefault struct {
 	Field string `smap:"EV.Value|FV.Service.URL"`
 }
 
+type ConfigIntBool struct {
+	Enabled bool `smap:"EV.Flag,intbool"`
+}
+
+type ConfigIntBoolStrict struct {
+	Enabled bool `smap:"EV.Flag,intbool=strict"`
+}
+
+type ConfigHydrateBool struct {
+	Enabled bool `smap:"EV.Value,hydrate"`
+	Debug   bool `smap:"EV.Flag,hydrate"`
+}
+
+type ConfigTriState struct {
+	Verbose *bool `smap:"EV.Verbose"`
+	Debug   *bool `smap:"EV.Value,hydrate"`
+}
+
+type ConfigIntNoBool struct {
+	Enabled bool `smap:"EV.Flag"`
+}
+
+type ConfigJSON struct {
+	Names []string `smap:"EV.Value,json"`
+	Ports []int    `smap:"EV.AISvcKey,json"`
+}
+
+type ConfigJSONStructs struct {
+	Servers []JSONServer `smap:"EV.Value,json"`
+}
+
+type ConfigJSONStructPtrs struct {
+	Servers []*JSONServer `smap:"EV.Value,json"`
+}
+
+type JSONServer struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type ConfigAllPaths struct {
+	Count int `smap:"EV.Count|FV.Count,allpaths"`
+}
+
+type ConfigBase64 struct {
+	Secret []byte `smap:"EV.Value,base64"`
+}
+
+type ConfigBase64URL struct {
+	Secret []byte `smap:"EV.Value,base64=url"`
+}
+
+type ID string
+
+type Port int
+
+type ConfigNamed struct {
+	ID   ID   `smap:"EV.AISvcKey"`
+	Port Port `smap:"EV.Count"`
+}
+
+type ConfigNamedMismatch struct {
+	Port Port `smap:"EV.Value"`
+}
+
+type ConfigURL struct {
+	URL    url.URL  `smap:"EV.AISvcURL,hydrate"`
+	URLPtr *url.URL `smap:"EV.AISvcURL,hydrate"`
+}
+
+type ConfigPointerWrap struct {
+	Count *int    `smap:"EV.Count"`
+	Key   *string `smap:"EV.AISvcKey"`
+	ID    *ID     `smap:"EV.AISvcKey"`
+}
+
+type ConfigTagKey struct {
+	Key string `cfg:"EV.AISvcKey"`
+	URL string `smap:"EV.AISvcURL"`
+}
+
+type ConfigSkipNil struct {
+	Users []string `smap:"EV.Users|FV.Users,skipnil"`
+	Value string   `smap:"EV.Value|FV.Value,skipnil"`
+}
+
+type ConfigGather struct {
+	Labels map[string]string      `smap:"EV.Labels.*"`
+	Data   map[string]string      `smap:"EV.Data.*"`
+	Any    map[string]interface{} `smap:"EV.Labels.*"`
+}
+
+type ConfigGatherPick struct {
+	Service map[string]string `smap:"EV.{AISvcURL,AISvcKey}"`
+	Data    map[string]string `smap:"EV.Data.{a,c},skipzero"`
+}
+
+type ConfigGatherPickAttached struct {
+	Service map[string]string `smap:"EV{AISvcURL,AISvcKey}"`
+}
+
+type ConfigGatherPickMissing struct {
+	Service map[string]string `smap:"EV.{AISvcURL,Missing}"`
+}
+
+type ConfigGatherInvalid struct {
+	Labels string `smap:"EV.Labels.*"`
+}
+
+type ConfigStringer struct {
+	IP string `smap:"EV.IP,stringer"`
+}
+
+type ConfigNoStringer struct {
+	IP string `smap:"EV.IP"`
+}
+
+type ConfigTagSplitter struct {
+	URL string `smap:"EV/AISvcURL FV/Service/URL;skipzero"`
+}
+
+type ConfigTagSplitterPick struct {
+	Picked map[string]string `smap:"EV/{AISvcURL;AISvcKey}"`
+}
+
+type ConfigNilOnZero struct {
+	Count *int `smap:"EV.Count,nilonzero"`
+}
+
+type ConfigOnError struct {
+	Optional int    `smap:"EV.Value,hydrate,onerror=skip"`
+	Critical string `smap:"EV.AISvcKey,onerror=fail"`
+}
+
+type ConfigOnErrorFail struct {
+	Count int `smap:"EV.Value,hydrate,onerror=fail"`
+}
+
+type ConfigInterfaceTarget struct {
+	Level fmt.Stringer `smap:"EV.Value,hydrate"`
+}
+
+type Level int
+
+func (l Level) String() string {
+	return [...]string{"low", "high"}[l]
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
 type Sources struct {
 	EV *EnvVars
 	FV *FileVals
@@ -69,11 +427,32 @@ type EnvVars struct {
 	IntMap   map[int]string
 	FloatMap map[float64]int
 	Users    []string
+	Flag     int
+	Labels   Labels
+	IP       net.IP
+	Servers  []Server
+	Backends map[string]*Server
+	Verbose  bool
+	Err      error
+	Raw      []byte
+}
+
+type Server struct {
+	Host string
+	Port int
+}
+
+type Labels struct {
+	Team  string
+	Stage string
+	count int
 }
 
 type FileVals struct {
 	Service FileValsService
 	Count   int // Add for skipzero tests
+	Users   []string
+	Value   string
 }
 
 type FileValsService struct {
@@ -84,11 +463,25 @@ func (e *EnvVars) GetValue() string {
 	return "method value"
 }
 
+// GetCount returns the count, which may be zero.
+func (e *EnvVars) GetCount() int {
+	return e.Count
+}
+
+// Primary returns the first server, or nil if there are none.
+func (e *EnvVars) Primary() *Server {
+	if len(e.Servers) == 0 {
+		return nil
+	}
+	return &e.Servers[0]
+}
+
 func TestSurfaceMerge(t *testing.T) {
 	tests := []struct {
 		name    string
 		dst     interface{}
 		src     interface{}
+		opts    []smap.Option
 		want    interface{}
 		wantErr error
 	}{
@@ -129,6 +522,64 @@ func TestSurfaceMerge(t *testing.T) {
 			want:    Config{},
 			wantErr: smap.ErrSrcInvalid,
 		},
+		{
+			name:    "nil_pointer_source_ok",
+			dst:     &Config{AISvcKey: "keep"},
+			src:     (*Sources)(nil),
+			opts:    []smap.Option{smap.WithNilSourceOK()},
+			want:    Config{AISvcKey: "keep"},
+			wantErr: nil,
+		},
+		{
+			name:    "nil_source_ok",
+			dst:     &Config{AISvcKey: "keep"},
+			src:     nil,
+			opts:    []smap.Option{smap.WithNilSourceOK()},
+			want:    Config{AISvcKey: "keep"},
+			wantErr: nil,
+		},
+		{
+			name:    "non_struct_source_with_nil_source_ok",
+			dst:     &Config{},
+			src:     1,
+			opts:    []smap.Option{smap.WithNilSourceOK()},
+			want:    Config{},
+			wantErr: smap.ErrSrcInvalid,
+		},
+		{
+			name:    "nil_in_double_pointer_source",
+			dst:     &Config{},
+			src:     func() **Sources { var p *Sources; return &p }(),
+			want:    Config{},
+			wantErr: smap.ErrSrcNil,
+		},
+		{
+			name: "double_pointer_source",
+			dst:  &Config{},
+			src: func() **Sources {
+				p := &Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"}}
+				return &p
+			}(),
+			want:    Config{AISvcURL: "env-url", AISvcKey: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "interface_pointer_source",
+			dst:  &Config{},
+			src: func() *interface{} {
+				var v interface{} = &Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"}}
+				return &v
+			}(),
+			want:    Config{AISvcURL: "env-url", AISvcKey: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name:    "non_struct_source",
+			dst:     &Config{},
+			src:     func() **int { n := 1; p := &n; return &p }(),
+			want:    Config{},
+			wantErr: smap.ErrSrcInvalid,
+		},
 		{
 			name: "invalid_path",
 			dst:  &Config{},
@@ -168,6 +619,48 @@ func TestSurfaceMerge(t *testing.T) {
 			want:    ConfigNilPath{},
 			wantErr: nil,
 		},
+		{
+			name: "empty_path_error",
+			dst:  &ConfigNilPath{NilPath: "keep"},
+			src: Sources{
+				EV: &EnvVars{Nil: nil},
+			},
+			opts:    []smap.Option{smap.WithEmptyPathError()},
+			want:    ConfigNilPath{NilPath: "keep"},
+			wantErr: smap.ErrPathUnresolved,
+		},
+		{
+			name: "empty_path_error_pointer_exempt",
+			dst:  &ConfigPointer{},
+			src: Sources{
+				EV: &EnvVars{URL: nil},
+				FV: &FileVals{Service: FileValsService{URL: nil}},
+			},
+			opts:    []smap.Option{smap.WithEmptyPathError()},
+			want:    ConfigPointer{},
+			wantErr: nil,
+		},
+		{
+			name: "empty_path_error_skipzero_exempt",
+			dst:  &ConfigSkipZero{Count: 7},
+			src: Sources{
+				EV: &EnvVars{Count: 0},
+				FV: &FileVals{Count: 0},
+			},
+			opts:    []smap.Option{smap.WithEmptyPathError()},
+			want:    ConfigSkipZero{Count: 7},
+			wantErr: nil,
+		},
+		{
+			name: "empty_path_error_resolved",
+			dst:  &ConfigNilPath{},
+			src: Sources{
+				EV: &EnvVars{Nil: &struct{ URL string }{URL: "nil-url"}},
+			},
+			opts:    []smap.Option{smap.WithEmptyPathError()},
+			want:    ConfigNilPath{NilPath: "nil-url"},
+			wantErr: nil,
+		},
 		{
 			name: "hydrate_string_to_int",
 			dst:  &ConfigHydrate{},
@@ -235,44 +728,3392 @@ func TestSurfaceMerge(t *testing.T) {
 			wantErr: nil,
 		},
 		{
-			name: "string_overwrites_default_with_nil_pointer_in_second_path",
-			dst:  &ConfigDefault{Field: "default"},
+			name: "skipzero_method_zero_keeps_existing",
+			dst:  &ConfigSkipZeroMethod{Count: 7},
 			src: Sources{
-				EV: &EnvVars{Value: "overwritten"},
-				FV: &FileVals{Service: FileValsService{URL: nil}},
+				EV: &EnvVars{Count: 0},
 			},
-			want: ConfigDefault{
-				Field: "overwritten",
+			want:    ConfigSkipZeroMethod{Count: 7},
+			wantErr: nil,
+		},
+		{
+			name: "skipzero_method_non-zero",
+			dst:  &ConfigSkipZeroMethod{Count: 7},
+			src: Sources{
+				EV: &EnvVars{Count: 3},
 			},
+			want:    ConfigSkipZeroMethod{Count: 3},
 			wantErr: nil,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := smap.Merge(tt.dst, tt.src)
-			if tt.wantErr != nil {
-				if err == nil {
-					t.Errorf("Merge() error = nil, want %v", tt.wantErr)
-					return
-				}
-				if !errors.Is(err, tt.wantErr) {
-					t.Errorf("Merge() error = %v, want %v", err, tt.wantErr)
-				}
-				return
-			}
-			if err != nil {
-				t.Errorf("Merge() error = %v, want nil", err)
-				return
-			}
-			if !reflect.DeepEqual(reflect.ValueOf(tt.dst).Elem().Interface(), tt.want) {
-				t.Errorf("Merge() dst = %+v, want %+v", reflect.ValueOf(tt.dst).Elem().Interface(), tt.want)
-			}
-		})
-	}
-}
-
-// Helper to create *string
-func strPtr(s string) *string {
-	return &s
+		{
+			name: "zero_as_absent",
+			dst:  &ConfigZeroAsAbsent{},
+			src: Sources{
+				EV: &EnvVars{Count: 7, Value: ""},
+				FV: &FileVals{Count: 0, Value: "file"},
+			},
+			opts:    []smap.Option{smap.WithZeroAsAbsent()},
+			want:    ConfigZeroAsAbsent{Count: 7, Value: ""},
+			wantErr: nil,
+		},
+		{
+			name: "zero_as_absent_unset",
+			dst:  &ConfigZeroAsAbsent{},
+			src: Sources{
+				EV: &EnvVars{Count: 7, Value: ""},
+				FV: &FileVals{Count: 0, Value: "file"},
+			},
+			want:    ConfigZeroAsAbsent{Count: 0, Value: ""},
+			wantErr: nil,
+		},
+		{
+			name: "preserve_non_zero_defaults",
+			dst:  &ConfigZeroAsAbsent{Count: 5, Value: "default"},
+			src: Sources{
+				EV: &EnvVars{Count: 7, Value: ""},
+				FV: &FileVals{Count: 0, Value: "file"},
+			},
+			opts:    []smap.Option{smap.WithPreserveNonZeroDefaults()},
+			want:    ConfigZeroAsAbsent{Count: 5, Value: ""},
+			wantErr: nil,
+		},
+		{
+			name: "preserve_non_zero_defaults_overwritten",
+			dst:  &ConfigZeroAsAbsent{Count: 5, Value: "default"},
+			src: Sources{
+				EV: &EnvVars{Count: 0, Value: "env"},
+				FV: &FileVals{Count: 9, Value: "file"},
+			},
+			opts:    []smap.Option{smap.WithPreserveNonZeroDefaults()},
+			want:    ConfigZeroAsAbsent{Count: 9, Value: "env"},
+			wantErr: nil,
+		},
+		{
+			name: "preserve_non_zero_defaults_unset",
+			dst:  &ConfigZeroAsAbsent{Count: 5, Value: "default"},
+			src: Sources{
+				EV: &EnvVars{Count: 7, Value: ""},
+				FV: &FileVals{Count: 0, Value: "file"},
+			},
+			want:    ConfigZeroAsAbsent{Count: 0, Value: ""},
+			wantErr: nil,
+		},
+		{
+			name: "projection",
+			dst:  &ConfigProjection{},
+			src: Sources{
+				EV: &EnvVars{Servers: []Server{{Host: "a", Port: 80}, {Host: "b", Port: 443}}},
+			},
+			want:    ConfigProjection{Hosts: []string{"a", "b"}, Ports: []Port{80, 443}},
+			wantErr: nil,
+		},
+		{
+			name: "projection_within_gather_limit",
+			dst:  &ConfigProjection{},
+			src: Sources{
+				EV: &EnvVars{Servers: []Server{{Host: "a", Port: 80}, {Host: "b", Port: 443}}},
+			},
+			opts:    []smap.Option{smap.WithMaxSliceGather(2)},
+			want:    ConfigProjection{Hosts: []string{"a", "b"}, Ports: []Port{80, 443}},
+			wantErr: nil,
+		},
+		{
+			name: "projection_exceeds_gather_limit",
+			dst:  &ConfigProjection{},
+			src: Sources{
+				EV: &EnvVars{Servers: []Server{{Host: "a", Port: 80}, {Host: "b", Port: 443}}},
+			},
+			opts:    []smap.Option{smap.WithMaxSliceGather(1)},
+			want:    ConfigProjection{},
+			wantErr: smap.ErrGatherLimitExceeded,
+		},
+		{
+			name: "gather_exceeds_gather_limit",
+			dst:  &ConfigGather{},
+			src: Sources{
+				EV: &EnvVars{Labels: Labels{Team: "core"}, Data: map[string]string{"a": "1"}},
+			},
+			opts:    []smap.Option{smap.WithMaxSliceGather(1)},
+			want:    ConfigGather{},
+			wantErr: smap.ErrGatherLimitExceeded,
+		},
+		{
+			name: "projection_empty_slice",
+			dst:  &ConfigProjection{},
+			src: Sources{
+				EV: &EnvVars{Servers: []Server{}},
+			},
+			want:    ConfigProjection{Hosts: []string{}, Ports: []Port{}},
+			wantErr: nil,
+		},
+		{
+			name: "projection_nil_slice",
+			dst:  &ConfigProjection{},
+			src: Sources{
+				EV: &EnvVars{},
+			},
+			want:    ConfigProjection{},
+			wantErr: nil,
+		},
+		{
+			name: "projection_type_mismatch",
+			dst:  &ConfigProjectionMismatch{},
+			src: Sources{
+				EV: &EnvVars{Servers: []Server{{Host: "a"}}},
+			},
+			want:    ConfigProjectionMismatch{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "max_fields_within_limit",
+			dst:  &Config{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			opts:    []smap.Option{smap.WithMaxFields(2)},
+			want:    Config{AISvcURL: "env-url", AISvcKey: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "max_fields_exceeded",
+			dst:  &Config{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			opts:    []smap.Option{smap.WithMaxFields(1)},
+			want:    Config{},
+			wantErr: smap.ErrTooManyFields,
+		},
+		{
+			name: "hydrate_complex",
+			dst:  &ConfigComplex{},
+			src: Sources{
+				EV: &EnvVars{Value: "1+2i"},
+			},
+			want:    ConfigComplex{Impedance: complex(1, 2)},
+			wantErr: nil,
+		},
+		{
+			name: "hydrate_complex64",
+			dst:  &ConfigComplex64{},
+			src: Sources{
+				EV: &EnvVars{Value: "(0.5-3i)"},
+			},
+			want:    ConfigComplex64{Impedance: complex(0.5, -3)},
+			wantErr: nil,
+		},
+		{
+			name: "hydrate_complex_malformed",
+			dst:  &ConfigComplex{},
+			src: Sources{
+				EV: &EnvVars{Value: "1+2j"},
+			},
+			want:    ConfigComplex{},
+			wantErr: strconv.ErrSyntax,
+		},
+		{
+			name: "rename_paths",
+			dst:  &ConfigPaths{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url"},
+				FV: &FileVals{Service: FileValsService{URL: strPtr("file-url")}},
+			},
+			opts: []smap.Option{smap.WithRenamePaths(map[string]string{
+				"EV.OldURL":         "EV.AISvcURL",
+				"EV.Old":            "EV.Missing",
+				"FV.Legacy":         "FV.Service",
+				"FV.LegacyServices": "FV.Missing",
+			})},
+			want:    ConfigPaths{URL: "env-url", FileURL: "file-url"},
+			wantErr: nil,
+		},
+		{
+			name: "path_prefix",
+			dst:  &Config{},
+			src: struct{ Prod, Dev Sources }{
+				Prod: Sources{EV: &EnvVars{AISvcURL: "prod-url", AISvcKey: "prod-key"}},
+				Dev:  Sources{EV: &EnvVars{AISvcURL: "dev-url", AISvcKey: "dev-key"}},
+			},
+			opts:    []smap.Option{smap.WithPathPrefix("Dev")},
+			want:    Config{AISvcURL: "dev-url", AISvcKey: "dev-key"},
+			wantErr: nil,
+		},
+		{
+			name: "path_prefix_multiple_segments_after_rename",
+			dst:  &ConfigPaths{},
+			src: struct{ Env struct{ Prod Sources } }{
+				Env: struct{ Prod Sources }{Prod: Sources{EV: &EnvVars{AISvcURL: "prod-url"}}},
+			},
+			opts: []smap.Option{
+				smap.WithPathPrefix("Env.Prod"),
+				smap.WithRenamePaths(map[string]string{"EV.OldURL": "EV.AISvcURL"}),
+			},
+			want:    ConfigPaths{URL: "prod-url"},
+			wantErr: nil,
+		},
+		{
+			name: "error_field_to_error",
+			dst:  &ConfigErrorField{},
+			src: Sources{
+				EV: &EnvVars{Err: errPortRange},
+			},
+			want:    ConfigErrorField{Err: errPortRange},
+			wantErr: nil,
+		},
+		{
+			name: "nil_error_field_to_error",
+			dst:  &ConfigErrorField{Err: errPortRange},
+			src: Sources{
+				EV: &EnvVars{},
+			},
+			want:    ConfigErrorField{},
+			wantErr: nil,
+		},
+		{
+			name: "error_field_to_string",
+			dst:  &ConfigErrorString{},
+			src: Sources{
+				EV: &EnvVars{Err: errPortRange},
+			},
+			want:    ConfigErrorString{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "error_field_to_string_stringer",
+			dst:  &ConfigErrorStringer{},
+			src: Sources{
+				EV: &EnvVars{Err: errPortRange},
+			},
+			want:    ConfigErrorStringer{Msg: "port out of range"},
+			wantErr: nil,
+		},
+		{
+			name: "skip_types",
+			dst:  &ConfigSkipTypes{URL: url.URL{Host: "manual"}},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "http://env", AISvcKey: "env-key", Count: 3},
+			},
+			opts:    []smap.Option{smap.WithSkipTypes(reflect.TypeOf(url.URL{}), reflect.TypeOf(0))},
+			want:    ConfigSkipTypes{URL: url.URL{Host: "manual"}, Key: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "integer_to_duration",
+			dst:  &ConfigDuration{},
+			src: Sources{
+				EV: &EnvVars{Count: 5, Value: "2s"},
+			},
+			want:    ConfigDuration{Timeout: 5 * time.Millisecond, Raw: 5, Parsed: 2 * time.Second},
+			wantErr: nil,
+		},
+		{
+			name: "integer_to_duration_pointer",
+			dst:  &ConfigDurationPtr{},
+			src: Sources{
+				EV: &EnvVars{Count: 3},
+			},
+			want:    ConfigDurationPtr{Timeout: durationPtr(3 * time.Second), Raw: durationPtr(3)},
+			wantErr: nil,
+		},
+		{
+			name: "integer_to_duration_unit_invalid",
+			dst:  &ConfigDurationUnitInvalid{},
+			src: Sources{
+				EV: &EnvVars{Count: 5},
+			},
+			want:    ConfigDurationUnitInvalid{},
+			wantErr: smap.ErrDurationUnitInvalid,
+		},
+		{
+			name: "integer_to_duration_overflow",
+			dst:  &ConfigDurationOverflow{},
+			src: Sources{
+				EV: &EnvVars{Count: math.MaxInt64 / 2},
+			},
+			want:    ConfigDurationOverflow{},
+			wantErr: smap.ErrNumberOverflow,
+		},
+		{
+			name: "path_not_found_error",
+			dst:  &ConfigMissingPath{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url"},
+			},
+			want:    ConfigMissingPath{},
+			wantErr: smap.ErrTagPathNotFound,
+		},
+		{
+			name: "path_not_found_keep",
+			dst:  &ConfigMissingPath{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url"},
+			},
+			opts:    []smap.Option{smap.WithPathNotFoundPolicy(smap.PathNotFoundKeep)},
+			want:    ConfigMissingPath{URL: "env-url"},
+			wantErr: nil,
+		},
+		{
+			name: "string_transforms",
+			dst:  &ConfigTransform{},
+			src: Sources{
+				EV: &EnvVars{
+					AISvcKey: "  Env-KEY\n",
+					Value:    " Mixed Case ",
+					Labels:   Labels{Team: "Core", Stage: "PROD"},
+					Servers:  []Server{{Host: " A.example "}, {Host: "B.EXAMPLE"}},
+				},
+			},
+			want: ConfigTransform{
+				Key:    "env-key",
+				ID:     "Mixed Case",
+				Labels: map[string]string{"Team": "core", "Stage": "prod"},
+				Hosts:  []string{"a.example", "b.example"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "gather_pick_attached",
+			dst:  &ConfigGatherPickAttached{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			want: ConfigGatherPickAttached{
+				Service: map[string]string{"AISvcURL": "env-url", "AISvcKey": "env-key"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "string_index",
+			dst:  &ConfigStringIndex{Missing: '?'},
+			src: Sources{
+				EV: &EnvVars{Value: "éa"},
+			},
+			want:    ConfigStringIndex{Initial: 0xc3, First: 'é', Missing: '?'},
+			wantErr: nil,
+		},
+		{
+			name: "binary_unmarshaler",
+			dst:  &ConfigBinary{},
+			src: Sources{
+				EV: &EnvVars{Raw: []byte{1, 2}, Value: base64.StdEncoding.EncodeToString([]byte{3, 4})},
+			},
+			want: ConfigBinary{
+				Version:    Version{Major: 1, Minor: 2},
+				VersionPtr: &Version{Major: 1, Minor: 2},
+				Encoded:    Version{Major: 3, Minor: 4},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "binary_unmarshaler_error",
+			dst:  &ConfigBinary{},
+			src: Sources{
+				EV: &EnvVars{Raw: []byte{1}},
+			},
+			want:    ConfigBinary{},
+			wantErr: errVersionLength,
+		},
+		{
+			name: "hydrate_sized_ints_in_range",
+			dst:  &ConfigSizedInts{},
+			src: Sources{
+				EV: &EnvVars{Value: "-128", AISvcKey: "255"},
+			},
+			want:    ConfigSizedInts{Small: -128, Byte: 255},
+			wantErr: nil,
+		},
+		{
+			name: "hydrate_int8_overflow",
+			dst:  &ConfigSizedInts{},
+			src: Sources{
+				EV: &EnvVars{Value: "300", AISvcKey: "1"},
+			},
+			want:    ConfigSizedInts{},
+			wantErr: smap.ErrValueOverflow,
+		},
+		{
+			name: "hydrate_uint8_overflow",
+			dst:  &ConfigSizedInts{},
+			src: Sources{
+				EV: &EnvVars{Value: "1", AISvcKey: "256"},
+			},
+			want:    ConfigSizedInts{Small: 1},
+			wantErr: smap.ErrValueOverflow,
+		},
+		{
+			name: "value_transform",
+			dst:  &Config{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "enc:yek"},
+			},
+			opts:    []smap.Option{smap.WithValueTransform(decryptSecret)},
+			want:    Config{AISvcURL: "env-url", AISvcKey: "key"},
+			wantErr: nil,
+		},
+		{
+			name: "value_transform_error",
+			dst:  &Config{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "plain"},
+			},
+			opts:    []smap.Option{smap.WithValueTransform(decryptSecret)},
+			want:    Config{AISvcURL: "env-url"},
+			wantErr: errSecretInvalid,
+		},
+		{
+			name: "value_transform_type_mismatch",
+			dst:  &Config{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url"},
+			},
+			opts: []smap.Option{smap.WithValueTransform(func(string, reflect.Value) (reflect.Value, error) {
+				return reflect.ValueOf(1), nil
+			})},
+			want:    Config{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "default_hydrate",
+			dst:  &ConfigDefaultHydrate{},
+			src: Sources{
+				EV: &EnvVars{Value: "42", AISvcKey: "3s", AISvcURL: "env-url", Count: 7},
+			},
+			opts:    []smap.Option{smap.WithDefaultHydrate()},
+			want:    ConfigDefaultHydrate{Count: 42, Timeout: 3 * time.Second, URL: "env-url"},
+			wantErr: nil,
+		},
+		{
+			name: "default_hydrate_opt_out",
+			dst:  &ConfigNoHydrate{},
+			src: Sources{
+				EV: &EnvVars{Value: "42"},
+			},
+			opts:    []smap.Option{smap.WithDefaultHydrate()},
+			want:    ConfigNoHydrate{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "default_hydrate_unset",
+			dst:  &ConfigDefaultHydrate{},
+			src: Sources{
+				EV: &EnvVars{Value: "42", AISvcKey: "3s", AISvcURL: "env-url", Count: 7},
+			},
+			want:    ConfigDefaultHydrate{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "method_pointer_result_navigated",
+			dst:  &ConfigMethodPointer{},
+			src: Sources{
+				EV: &EnvVars{Servers: []Server{{Host: "primary"}, {Host: "secondary"}}},
+				FV: &FileVals{Value: "file-host"},
+			},
+			want:    ConfigMethodPointer{Host: "primary"},
+			wantErr: nil,
+		},
+		{
+			name: "method_nil_pointer_result_unset",
+			dst:  &ConfigMethodPointer{},
+			src: Sources{
+				EV: &EnvVars{},
+				FV: &FileVals{Value: "file-host"},
+			},
+			want:    ConfigMethodPointer{Host: "file-host"},
+			wantErr: nil,
+		},
+		{
+			name: "strict_assignability_exact",
+			dst:  &Config{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			opts:    []smap.Option{smap.WithStrictAssignability()},
+			want:    Config{AISvcURL: "env-url", AISvcKey: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "strict_assignability_named",
+			dst:  &ConfigStrictNamed{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "env-key"},
+			},
+			opts:    []smap.Option{smap.WithStrictAssignability()},
+			want:    ConfigStrictNamed{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "strict_assignability_hydrate",
+			dst:  &ConfigStrictHydrate{},
+			src: Sources{
+				EV: &EnvVars{Value: "42"},
+			},
+			opts:    []smap.Option{smap.WithStrictAssignability()},
+			want:    ConfigStrictHydrate{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "strict_assignability_pointer",
+			dst:  &ConfigStrictPointer{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "env-key"},
+			},
+			opts:    []smap.Option{smap.WithStrictAssignability()},
+			want:    ConfigStrictPointer{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "setter_called_with_converted_value",
+			dst:  &ConfigSetter{},
+			src: Sources{
+				EV: &EnvVars{Value: "8080"},
+			},
+			want:    ConfigSetter{port: 8080},
+			wantErr: nil,
+		},
+		{
+			name: "setter_error",
+			dst:  &ConfigSetter{port: 1},
+			src: Sources{
+				EV: &EnvVars{Value: "-1"},
+			},
+			want:    ConfigSetter{port: 1},
+			wantErr: errPortInvalid,
+		},
+		{
+			name: "setter_missing",
+			dst:  &ConfigSetterMissing{},
+			src: Sources{
+				EV: &EnvVars{Value: "8080"},
+			},
+			want:    ConfigSetterMissing{},
+			wantErr: smap.ErrSetterInvalid,
+		},
+		{
+			name: "setter_signature_invalid",
+			dst:  &ConfigSetterSignature{},
+			src: Sources{
+				EV: &EnvVars{Value: "8080"},
+			},
+			want:    ConfigSetterSignature{},
+			wantErr: smap.ErrSetterInvalid,
+		},
+		{
+			name: "sql_null_types",
+			dst:  &ConfigNullable{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "env-key", Count: 42, Value: "8080"},
+			},
+			want: ConfigNullable{
+				Key:   sql.NullString{String: "env-key", Valid: true},
+				Count: sql.NullInt64{Int64: 42, Valid: true},
+				Port:  sql.NullInt32{Int32: 8080, Valid: true},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "sql_null_types_zero_count_is_valid",
+			dst:  &ConfigNullable{},
+			src: Sources{
+				EV: &EnvVars{Count: 0, Value: "0"},
+			},
+			want: ConfigNullable{
+				Key:   sql.NullString{String: "", Valid: true},
+				Count: sql.NullInt64{Int64: 0, Valid: true},
+				Port:  sql.NullInt32{Int32: 0, Valid: true},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "hydrate_bool_tokens_true",
+			dst:  &ConfigHydrateBool{},
+			src: Sources{
+				EV: &EnvVars{Value: " Yes ", Flag: 1},
+			},
+			want:    ConfigHydrateBool{Enabled: true, Debug: true},
+			wantErr: nil,
+		},
+		{
+			name: "hydrate_bool_tokens_false",
+			dst:  &ConfigHydrateBool{Enabled: true, Debug: true},
+			src: Sources{
+				EV: &EnvVars{Value: "OFF", Flag: 0},
+			},
+			want:    ConfigHydrateBool{},
+			wantErr: nil,
+		},
+		{
+			name: "hydrate_bool_unrecognized_token",
+			dst:  &ConfigHydrateBool{},
+			src: Sources{
+				EV: &EnvVars{Value: "maybe"},
+			},
+			want:    ConfigHydrateBool{},
+			wantErr: smap.ErrBoolInvalid,
+		},
+		{
+			name: "hydrate_bool_unrecognized_number",
+			dst:  &ConfigHydrateBool{},
+			src: Sources{
+				EV: &EnvVars{Value: "on", Flag: 2},
+			},
+			want:    ConfigHydrateBool{Enabled: true},
+			wantErr: smap.ErrBoolInvalid,
+		},
+		{
+			name: "tri_state_absent",
+			dst:  &ConfigTriState{},
+			src:  Sources{},
+			want: ConfigTriState{},
+		},
+		{
+			name: "tri_state_true",
+			dst:  &ConfigTriState{},
+			src: Sources{
+				EV: &EnvVars{Verbose: true, Value: "on"},
+			},
+			want:    ConfigTriState{Verbose: boolPtr(true), Debug: boolPtr(true)},
+			wantErr: nil,
+		},
+		{
+			name: "tri_state_false",
+			dst:  &ConfigTriState{},
+			src: Sources{
+				EV: &EnvVars{Verbose: false, Value: "false"},
+			},
+			want:    ConfigTriState{Verbose: boolPtr(false), Debug: boolPtr(false)},
+			wantErr: nil,
+		},
+		{
+			name: "string_overwrites_default_with_nil_pointer_in_second_path",
+			dst:  &ConfigDefault{Field: "default"},
+			src: Sources{
+				EV: &EnvVars{Value: "overwritten"},
+				FV: &FileVals{Service: FileValsService{URL: nil}},
+			},
+			want: ConfigDefault{
+				Field: "overwritten",
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "intbool_zero",
+			dst:     &ConfigIntBool{Enabled: true},
+			src:     Sources{EV: &EnvVars{Flag: 0}},
+			want:    ConfigIntBool{Enabled: false},
+			wantErr: nil,
+		},
+		{
+			name:    "intbool_non-zero",
+			dst:     &ConfigIntBool{},
+			src:     Sources{EV: &EnvVars{Flag: 7}},
+			want:    ConfigIntBool{Enabled: true},
+			wantErr: nil,
+		},
+		{
+			name:    "intbool_strict_one",
+			dst:     &ConfigIntBoolStrict{},
+			src:     Sources{EV: &EnvVars{Flag: 1}},
+			want:    ConfigIntBoolStrict{Enabled: true},
+			wantErr: nil,
+		},
+		{
+			name:    "intbool_strict_ambiguous",
+			dst:     &ConfigIntBoolStrict{},
+			src:     Sources{EV: &EnvVars{Flag: -1}},
+			want:    ConfigIntBoolStrict{},
+			wantErr: smap.ErrIntBoolInvalid,
+		},
+		{
+			name:    "int_to_bool_without_option",
+			dst:     &ConfigIntNoBool{},
+			src:     Sources{EV: &EnvVars{Flag: 1}},
+			want:    ConfigIntNoBool{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "json_array_to_slices",
+			dst:  &ConfigJSON{},
+			src: Sources{
+				EV: &EnvVars{Value: `["a","b"]`, AISvcKey: `[80, 443]`},
+			},
+			want:    ConfigJSON{Names: []string{"a", "b"}, Ports: []int{80, 443}},
+			wantErr: nil,
+		},
+		{
+			name: "json_array_to_struct_slice",
+			dst:  &ConfigJSONStructs{},
+			src: Sources{
+				EV: &EnvVars{Value: `[{"host":"a","port":1},{"host":"b","port":2}]`},
+			},
+			want: ConfigJSONStructs{Servers: []JSONServer{
+				{Host: "a", Port: 1},
+				{Host: "b", Port: 2},
+			}},
+			wantErr: nil,
+		},
+		{
+			name: "json_array_to_struct_pointer_slice",
+			dst:  &ConfigJSONStructPtrs{},
+			src: Sources{
+				EV: &EnvVars{Value: `[{"host":"a","port":1},null]`},
+			},
+			want: ConfigJSONStructPtrs{Servers: []*JSONServer{
+				{Host: "a", Port: 1},
+				nil,
+			}},
+			wantErr: nil,
+		},
+		{
+			name: "allpaths_all_resolve",
+			dst:  &ConfigAllPaths{},
+			src: Sources{
+				EV: &EnvVars{Count: 1},
+				FV: &FileVals{Count: 2},
+			},
+			want:    ConfigAllPaths{Count: 2},
+			wantErr: nil,
+		},
+		{
+			name: "allpaths_missing_path",
+			dst:  &ConfigAllPaths{},
+			src: Sources{
+				EV: &EnvVars{Count: 1},
+			},
+			want:    ConfigAllPaths{},
+			wantErr: smap.ErrPathIncomplete,
+		},
+		{
+			name: "require_all_paths_option",
+			dst:  &ConfigSkipZero{},
+			src: Sources{
+				FV: &FileVals{Count: 2},
+			},
+			opts:    []smap.Option{smap.WithRequireAllPathsResolve()},
+			want:    ConfigSkipZero{},
+			wantErr: smap.ErrPathIncomplete,
+		},
+		{
+			name:    "base64_std",
+			dst:     &ConfigBase64{},
+			src:     Sources{EV: &EnvVars{Value: "c2VjcmV0Pz8/"}},
+			want:    ConfigBase64{Secret: []byte("secret???")},
+			wantErr: nil,
+		},
+		{
+			name:    "base64_url",
+			dst:     &ConfigBase64URL{},
+			src:     Sources{EV: &EnvVars{Value: "c2VjcmV0Pz8_"}},
+			want:    ConfigBase64URL{Secret: []byte("secret???")},
+			wantErr: nil,
+		},
+		{
+			name:    "base64_invalid",
+			dst:     &ConfigBase64{},
+			src:     Sources{EV: &EnvVars{Value: "c2VjcmV0Pz8_"}},
+			want:    ConfigBase64{},
+			wantErr: base64.CorruptInputError(11),
+		},
+		{
+			name: "fill_only_zero",
+			dst:  &Config{AISvcURL: "user-url"},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			opts:    []smap.Option{smap.WithFillOnlyZero()},
+			want:    Config{AISvcURL: "user-url", AISvcKey: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "named_types_same_kind",
+			dst:  &ConfigNamed{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "abc", Count: 8080},
+			},
+			want:    ConfigNamed{ID: "abc", Port: 8080},
+			wantErr: nil,
+		},
+		{
+			name: "named_types_different_kind",
+			dst:  &ConfigNamedMismatch{},
+			src: Sources{
+				EV: &EnvVars{Value: "8080"},
+			},
+			want:    ConfigNamedMismatch{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "hydrate_url",
+			dst:  &ConfigURL{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "https://example.com/api?x=1"},
+			},
+			want: ConfigURL{
+				URL:    url.URL{Scheme: "https", Host: "example.com", Path: "/api", RawQuery: "x=1"},
+				URLPtr: &url.URL{Scheme: "https", Host: "example.com", Path: "/api", RawQuery: "x=1"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "concurrent_fields",
+			dst:  &Config{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+				FV: &FileVals{Service: FileValsService{URL: strPtr("file-url")}},
+			},
+			opts:    []smap.Option{smap.WithConcurrentFields(2)},
+			want:    Config{AISvcURL: "file-url", AISvcKey: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "concurrent_fields_error",
+			dst:  &ConfigIntBoolStrict{},
+			src: Sources{
+				EV: &EnvVars{Flag: 2},
+			},
+			opts:    []smap.Option{smap.WithConcurrentFields(4)},
+			want:    ConfigIntBoolStrict{},
+			wantErr: smap.ErrIntBoolInvalid,
+		},
+		{
+			name: "concurrent_fields_setters",
+			dst:  &ConfigSetters{},
+			src: Sources{
+				EV: &EnvVars{Value: "8080", AISvcKey: "env-key", AISvcURL: "env-url"},
+			},
+			opts:    []smap.Option{smap.WithConcurrentFields(4)},
+			want:    ConfigSetters{calls: []string{"8080", "env-key"}, URL: "env-url"},
+			wantErr: nil,
+		},
+		{
+			name: "pointer_destination_from_value",
+			dst:  &ConfigPointerWrap{},
+			src: Sources{
+				EV: &EnvVars{Count: 3, AISvcKey: "env-key"},
+			},
+			want:    ConfigPointerWrap{Count: intPtr(3), Key: strPtr("env-key"), ID: idPtr("env-key")},
+			wantErr: nil,
+		},
+		{
+			name:    "pointer_destination_unresolved",
+			dst:     &ConfigPointerWrap{},
+			src:     Sources{},
+			want:    ConfigPointerWrap{},
+			wantErr: nil,
+		},
+		{
+			name: "custom_tag_key",
+			dst:  &ConfigTagKey{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			opts:    []smap.Option{smap.WithTagKey("cfg")},
+			want:    ConfigTagKey{Key: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "skipnil_skips_nil_slice",
+			dst:  &ConfigSkipNil{},
+			src: Sources{
+				EV: &EnvVars{Users: []string{"alice"}, Value: "env"},
+				FV: &FileVals{Users: nil, Value: "file"},
+			},
+			want:    ConfigSkipNil{Users: []string{"alice"}, Value: "file"},
+			wantErr: nil,
+		},
+		{
+			name: "skipnil_assigns_empty_slice_and_zero_string",
+			dst:  &ConfigSkipNil{},
+			src: Sources{
+				EV: &EnvVars{Users: []string{"alice"}, Value: "env"},
+				FV: &FileVals{Users: []string{}, Value: ""},
+			},
+			want:    ConfigSkipNil{Users: []string{}, Value: ""},
+			wantErr: nil,
+		},
+		{
+			name: "value_validator_accepts",
+			dst:  &ConfigNamed{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "abc", Count: 8080},
+			},
+			opts:    []smap.Option{smap.WithValueValidator(validatePort)},
+			want:    ConfigNamed{ID: "abc", Port: 8080},
+			wantErr: nil,
+		},
+		{
+			name: "value_validator_rejects",
+			dst:  &ConfigNamed{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "abc", Count: 70000},
+			},
+			opts:    []smap.Option{smap.WithValueValidator(validatePort)},
+			want:    ConfigNamed{ID: "abc"},
+			wantErr: errPortRange,
+		},
+		{
+			name: "gather_struct_and_map",
+			dst:  &ConfigGather{},
+			src: Sources{
+				EV: &EnvVars{
+					Labels: Labels{Team: "core", Stage: "prod", count: 1},
+					Data:   map[string]string{"a": "1", "b": "2"},
+				},
+			},
+			want: ConfigGather{
+				Labels: map[string]string{"Team": "core", "Stage": "prod"},
+				Data:   map[string]string{"a": "1", "b": "2"},
+				Any:    map[string]interface{}{"Team": "core", "Stage": "prod"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "gather_nil_container",
+			dst:  &ConfigGather{},
+			src: Sources{
+				EV: nil,
+			},
+			want:    ConfigGather{},
+			wantErr: nil,
+		},
+		{
+			name: "gather_into_non_map",
+			dst:  &ConfigGatherInvalid{},
+			src: Sources{
+				EV: &EnvVars{},
+			},
+			want:    ConfigGatherInvalid{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "stringer_to_string",
+			dst:  &ConfigStringer{},
+			src: Sources{
+				EV: &EnvVars{IP: net.IPv4(10, 0, 0, 1)},
+			},
+			want:    ConfigStringer{IP: "10.0.0.1"},
+			wantErr: nil,
+		},
+		{
+			name: "stringer_without_option",
+			dst:  &ConfigNoStringer{},
+			src: Sources{
+				EV: &EnvVars{IP: net.IPv4(10, 0, 0, 1)},
+			},
+			want:    ConfigNoStringer{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "alloc_maps_merges_entries",
+			dst: &ConfigGather{
+				Labels: map[string]string{"Owner": "ops", "Team": "old"},
+			},
+			src: Sources{
+				EV: &EnvVars{Labels: Labels{Team: "core", Stage: "prod"}},
+			},
+			opts: []smap.Option{smap.WithAllocMaps()},
+			want: ConfigGather{
+				Labels: map[string]string{"Owner": "ops", "Team": "core", "Stage": "prod"},
+				Any:    map[string]interface{}{"Team": "core", "Stage": "prod"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "anonymous_struct_destination",
+			dst: &struct {
+				X string `smap:"EV.AISvcKey"`
+			}{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "env-key"},
+			},
+			want: struct {
+				X string `smap:"EV.AISvcKey"`
+			}{X: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "anonymous_struct_destination_error",
+			dst: &struct {
+				X int `smap:"EV.AISvcKey"`
+			}{},
+			src: Sources{
+				EV: &EnvVars{AISvcKey: "env-key"},
+			},
+			want: struct {
+				X int `smap:"EV.AISvcKey"`
+			}{},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+		{
+			name: "custom_tag_splitter",
+			dst:  &ConfigTagSplitter{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url"},
+				FV: &FileVals{Service: FileValsService{URL: strPtr("")}},
+			},
+			opts: []smap.Option{smap.WithTagSplitter(smap.TagSplitter{
+				Paths: " ", Options: ";", Segments: "/",
+			})},
+			want:    ConfigTagSplitter{URL: "env-url"},
+			wantErr: nil,
+		},
+		{
+			name: "custom_tag_splitter_pick",
+			dst:  &ConfigTagSplitterPick{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key", Value: "v"},
+			},
+			opts: []smap.Option{smap.WithTagSplitter(smap.TagSplitter{
+				Paths: " ", Options: ";", Segments: "/",
+			})},
+			want:    ConfigTagSplitterPick{Picked: map[string]string{"AISvcURL": "env-url", "AISvcKey": "env-key"}},
+			wantErr: nil,
+		},
+		{
+			name: "conflicting_tag_splitter",
+			dst:  &ConfigTagSplitter{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url"},
+			},
+			opts: []smap.Option{smap.WithTagSplitter(smap.TagSplitter{
+				Paths: "/", Options: ";", Segments: "/",
+			})},
+			want:    ConfigTagSplitter{},
+			wantErr: smap.ErrTagSplitterInvalid,
+		},
+		{
+			name: "nilonzero_zero_source",
+			dst:  &ConfigNilOnZero{},
+			src: Sources{
+				EV: &EnvVars{Count: 0},
+			},
+			want:    ConfigNilOnZero{Count: nil},
+			wantErr: nil,
+		},
+		{
+			name: "nilonzero_zero_source_keeps_populated",
+			dst:  &ConfigNilOnZero{Count: intPtr(5)},
+			src: Sources{
+				EV: &EnvVars{Count: 0},
+			},
+			want:    ConfigNilOnZero{Count: intPtr(5)},
+			wantErr: nil,
+		},
+		{
+			name: "nilonzero_non-zero_source",
+			dst:  &ConfigNilOnZero{},
+			src: Sources{
+				EV: &EnvVars{Count: 3},
+			},
+			want:    ConfigNilOnZero{Count: intPtr(3)},
+			wantErr: nil,
+		},
+		{
+			name: "pointer_destination_zero_source",
+			dst:  &ConfigPointerWrap{},
+			src: Sources{
+				EV: &EnvVars{Count: 0, AISvcKey: ""},
+			},
+			want:    ConfigPointerWrap{Count: intPtr(0), Key: strPtr(""), ID: idPtr("")},
+			wantErr: nil,
+		},
+		{
+			name: "tag_key_for_type",
+			dst:  &ConfigTagKey{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			opts: []smap.Option{smap.WithTagKeyForType(map[reflect.Type]string{
+				reflect.TypeOf(ConfigTagKey{}): "cfg",
+			})},
+			want:    ConfigTagKey{Key: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "tag_key_for_other_type",
+			dst:  &ConfigTagKey{},
+			src: Sources{
+				EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+			},
+			opts: []smap.Option{smap.WithTagKeyForType(map[reflect.Type]string{
+				reflect.TypeOf(Config{}): "cfg",
+			})},
+			want:    ConfigTagKey{URL: "env-url"},
+			wantErr: nil,
+		},
+		{
+			name: "onerror_skip",
+			dst:  &ConfigOnError{Optional: 7},
+			src: Sources{
+				EV: &EnvVars{Value: "not-a-number", AISvcKey: "env-key"},
+			},
+			want:    ConfigOnError{Optional: 7, Critical: "env-key"},
+			wantErr: nil,
+		},
+		{
+			name: "onerror_fail",
+			dst:  &ConfigOnErrorFail{},
+			src: Sources{
+				EV: &EnvVars{Value: "not-a-number"},
+			},
+			want:    ConfigOnErrorFail{},
+			wantErr: strconv.ErrSyntax,
+		},
+		{
+			name: "gather_picked_fields_and_keys",
+			dst:  &ConfigGatherPick{},
+			src: Sources{
+				EV: &EnvVars{
+					AISvcURL: "env-url",
+					AISvcKey: "env-key",
+					Count:    3,
+					Data:     map[string]string{"a": "1", "b": "2"},
+				},
+			},
+			want: ConfigGatherPick{
+				Service: map[string]string{"AISvcURL": "env-url", "AISvcKey": "env-key"},
+				Data:    map[string]string{"a": "1"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "gather_picked_missing_field",
+			dst:  &ConfigGatherPickMissing{},
+			src: Sources{
+				EV: &EnvVars{},
+			},
+			want:    ConfigGatherPickMissing{},
+			wantErr: smap.ErrTagPathNotFound,
+		},
+		{
+			name: "interface_target",
+			dst:  &ConfigInterfaceTarget{},
+			src: Sources{
+				EV: &EnvVars{Value: "high"},
+			},
+			opts: []smap.Option{smap.WithInterfaceTarget(
+				reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), reflect.TypeOf(Level(0)),
+			)},
+			want:    ConfigInterfaceTarget{Level: Level(1)},
+			wantErr: nil,
+		},
+		{
+			name: "interface_target_missing",
+			dst:  &ConfigInterfaceTarget{},
+			src: Sources{
+				EV: &EnvVars{Value: "high"},
+			},
+			want:    ConfigInterfaceTarget{},
+			wantErr: smap.ErrInterfaceTargetMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := smap.Merge(tt.dst, tt.src, tt.opts...)
+			if tt.wantErr != nil {
+				if err == nil {
+					t.Errorf("Merge() error = nil, want %v", tt.wantErr)
+					return
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Merge() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Merge() error = %v, want nil", err)
+				return
+			}
+			if !reflect.DeepEqual(reflect.ValueOf(tt.dst).Elem().Interface(), tt.want) {
+				t.Errorf("Merge() dst = %+v, want %+v", reflect.ValueOf(tt.dst).Elem().Interface(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceMergeJSONStructsError(t *testing.T) {
+	var dst ConfigJSONStructs
+	src := Sources{EV: &EnvVars{Value: `[{"host":"a","port":"1"}]`}}
+
+	err := smap.Merge(&dst, src)
+	var fieldErr *smap.MergeFieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Merge() error = %v, want *MergeFieldError", err)
+	}
+	if fieldErr.DstTypeName != "[]smap_test.JSONServer" {
+		t.Errorf("MergeFieldError.DstTypeName = %q, want %q", fieldErr.DstTypeName, "[]smap_test.JSONServer")
+	}
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("Merge() error = %v, want wrapped *json.UnmarshalTypeError", err)
+	}
+	if dst.Servers != nil {
+		t.Errorf("Merge() Servers = %+v, want nil after error", dst.Servers)
+	}
+}
+
+func TestSurfaceInspect(t *testing.T) {
+	type Inspected struct {
+		URL   string `smap:"EV.AISvcURL|FV.Service.URL"`
+		Count *int   `smap:"EV.Count,skipzero,nilonzero"`
+		Bad   string `smap:"EV..Value"`
+		Other string
+	}
+
+	tests := []struct {
+		name string
+		dst  interface{}
+		opts []smap.Option
+		want string
+	}{
+		{
+			name: "struct_pointer",
+			dst:  &Inspected{},
+			want: "smap_test.Inspected\n" +
+				"\tURL string\n" +
+				"\t\tpath: EV.AISvcURL\n" +
+				"\t\tpath: FV.Service.URL\n" +
+				"\tCount *int\n" +
+				"\t\tpath: EV.Count\n" +
+				"\t\toption: skipzero\n" +
+				"\t\toption: nilonzero\n" +
+				"\tBad string\n" +
+				"\t\terror: tag \"EV..Value\": empty segment in path: invalid path in tag\n",
+		},
+		{
+			name: "custom_tag_key",
+			dst:  ConfigTagKey{},
+			opts: []smap.Option{smap.WithTagKey("cfg")},
+			want: "smap_test.ConfigTagKey\n" +
+				"\tKey string\n" +
+				"\t\tpath: EV.AISvcKey\n",
+		},
+		{name: "not_struct", dst: "value", want: ""},
+		{name: "nil", dst: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := smap.Inspect(tt.dst, tt.opts...); got != tt.want {
+				t.Errorf("Inspect() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Mode implements both encoding.TextUnmarshaler and flag.Value, recording
+// which one hydrated it.
+type Mode struct {
+	Name string
+	Via  string
+}
+
+var errModeEmpty = errors.New("mode must not be empty")
+
+func (m *Mode) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		return errModeEmpty
+	}
+	*m = Mode{Name: string(text), Via: "text"}
+	return nil
+}
+
+func (m *Mode) Set(s string) error {
+	*m = Mode{Name: s, Via: "flag"}
+	return nil
+}
+
+func (m *Mode) String() string { return m.Name }
+
+// Switch implements only flag.Value.
+type Switch bool
+
+func (s *Switch) Set(v string) error {
+	*s = v == "on"
+	return nil
+}
+
+func (s *Switch) String() string { return strconv.FormatBool(bool(*s)) }
+
+// Celsius has a registered converter that requires a unit suffix.
+type Celsius float64
+
+var errCelsiusUnit = errors.New("missing °C unit")
+
+func TestSurfaceDiffStructs(t *testing.T) {
+	type Diffed struct {
+		URL    string            `smap:"EV.AISvcURL"`
+		Labels map[string]string `smap:"EV.Labels"`
+		Port   *int              `smap:"EV.Count"`
+		Other  string
+	}
+	one, two := 1, 1
+
+	tests := []struct {
+		name    string
+		a, b    interface{}
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "equal_deep",
+			a:    Diffed{URL: "u", Labels: map[string]string{"a": "1"}, Port: &one},
+			b:    &Diffed{URL: "u", Labels: map[string]string{"a": "1"}, Port: &two},
+			want: nil,
+		},
+		{
+			name: "untagged_ignored",
+			a:    &Diffed{Other: "a"},
+			b:    &Diffed{Other: "b"},
+			want: nil,
+		},
+		{
+			name: "tagged_differ",
+			a:    &Diffed{URL: "a", Labels: map[string]string{"a": "1"}, Port: &one},
+			b:    &Diffed{URL: "b", Labels: map[string]string{"a": "2"}, Port: &one},
+			want: []string{"URL", "Labels"},
+		},
+		{
+			name: "nil_versus_set",
+			a:    &Diffed{},
+			b:    &Diffed{Port: &one},
+			want: []string{"Port"},
+		},
+		{
+			name:    "types_differ",
+			a:       &Diffed{},
+			b:       &EnvVars{},
+			wantErr: smap.ErrDiffInvalid,
+		},
+		{
+			name:    "nil_pointer",
+			a:       &Diffed{},
+			b:       (*Diffed)(nil),
+			wantErr: smap.ErrDiffInvalid,
+		},
+		{
+			name:    "not_struct",
+			a:       "a",
+			b:       "b",
+			wantErr: smap.ErrDiffInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := smap.DiffStructs(tt.a, tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DiffStructs() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffStructs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceHydrateChain(t *testing.T) {
+	smap.RegisterConverter(reflect.TypeOf(Celsius(0)), func(dst interface{}, s string) error {
+		if !strings.HasSuffix(s, "°C") {
+			return errCelsiusUnit
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "°C"), 64)
+		*dst.(*Celsius) = Celsius(f)
+		return err
+	})
+	smap.RegisterConverter(reflect.TypeOf(Mode{}), func(dst interface{}, s string) error {
+		if !strings.HasPrefix(s, "conv:") {
+			return errors.New("not a converter mode")
+		}
+		*dst.(*Mode) = Mode{Name: strings.TrimPrefix(s, "conv:"), Via: "converter"}
+		return nil
+	})
+
+	type Record struct {
+		Mode    Mode    `smap:"EV.Value,hydrate"`
+		ModePtr *Mode   `smap:"EV.Value,hydrate"`
+		Switch  Switch  `smap:"EV.AISvcKey,hydrate"`
+		Temp    Celsius `smap:"EV.AISvcURL,hydrate"`
+	}
+
+	tests := []struct {
+		name    string
+		src     *EnvVars
+		want    Record
+		wantErr error
+	}{
+		{
+			name: "converter_first",
+			src:  &EnvVars{Value: "conv:fast", AISvcKey: "on", AISvcURL: "21.5°C"},
+			want: Record{
+				Mode:    Mode{Name: "fast", Via: "converter"},
+				ModePtr: &Mode{Name: "conv:fast", Via: "text"},
+				Switch:  true,
+				Temp:    21.5,
+			},
+		},
+		{
+			name: "converter_failure_falls_back_to_text_before_flag",
+			src:  &EnvVars{Value: "fast", AISvcKey: "off", AISvcURL: "0°C"},
+			want: Record{
+				Mode:    Mode{Name: "fast", Via: "text"},
+				ModePtr: &Mode{Name: "fast", Via: "text"},
+			},
+		},
+		{
+			name:    "all_hydrators_fail",
+			src:     &EnvVars{Value: "fast", AISvcKey: "on", AISvcURL: "warm"},
+			want:    Record{Mode: Mode{Name: "fast", Via: "text"}, ModePtr: &Mode{Name: "fast", Via: "text"}, Switch: true},
+			wantErr: errCelsiusUnit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Record
+			err := smap.Merge(&got, Sources{EV: tt.src})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("text_failure_falls_back_to_flag", func(t *testing.T) {
+		type Record struct {
+			Mode Mode `smap:"EV.Value,hydrate"`
+		}
+		var got Record
+		if err := smap.Merge(&got, Sources{EV: &EnvVars{Value: ""}}); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if want := (Mode{Via: "flag"}); got.Mode != want {
+			t.Errorf("Merge() Mode = %+v, want %+v", got.Mode, want)
+		}
+	})
+}
+
+// Endpoint wraps a parsed URL and can only be built by ParseEndpoint.
+type Endpoint struct {
+	u *url.URL
+}
+
+var errEndpointScheme = errors.New("endpoint scheme must be https")
+
+func ParseEndpoint(s string) (Endpoint, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	if u.Scheme != "https" {
+		return Endpoint{}, errEndpointScheme
+	}
+	return Endpoint{u: u}, nil
+}
+
+func (e Endpoint) String() string { return e.u.String() }
+
+func TestSurfaceRegisterParser(t *testing.T) {
+	smap.RegisterParser(reflect.TypeOf(Endpoint{}), func(s string) (interface{}, error) {
+		return ParseEndpoint(s)
+	})
+	type Record struct {
+		Endpoint Endpoint `smap:"EV.AISvcURL,hydrate"`
+	}
+
+	var got Record
+	if err := smap.Merge(&got, Sources{EV: &EnvVars{AISvcURL: "https://ai.example"}}); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if got.Endpoint.String() != "https://ai.example" {
+		t.Errorf("Merge() Endpoint = %v, want %v", got.Endpoint, "https://ai.example")
+	}
+
+	err := smap.Merge(&got, Sources{EV: &EnvVars{AISvcURL: "http://ai.example"}})
+	if !errors.Is(err, errEndpointScheme) {
+		t.Fatalf("Merge() error = %v, want %v", err, errEndpointScheme)
+	}
+}
+
+func TestSurfaceRegisteredPrecedence(t *testing.T) {
+	urlType, nullType := reflect.TypeOf(url.URL{}), reflect.TypeOf(sql.NullInt64{})
+	t.Cleanup(func() {
+		smap.RegisterConverter(urlType, nil)
+		smap.RegisterConverter(nullType, nil)
+	})
+	smap.RegisterConverter(urlType, func(dst interface{}, s string) error {
+		dst.(*url.URL).Host = "converted." + s
+		return nil
+	})
+	smap.RegisterConverter(nullType, func(dst interface{}, s string) error {
+		*dst.(*sql.NullInt64) = sql.NullInt64{Int64: int64(len(s)), Valid: true}
+		return nil
+	})
+	type Record struct {
+		URL   url.URL       `smap:"EV.AISvcURL,hydrate"`
+		Count sql.NullInt64 `smap:"EV.AISvcKey,hydrate"`
+	}
+
+	var got Record
+	if err := smap.Merge(&got, Sources{EV: &EnvVars{AISvcURL: "example", AISvcKey: "abc"}}); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if got.URL.Host != "converted.example" {
+		t.Errorf("Merge() URL.Host = %q, want %q", got.URL.Host, "converted.example")
+	}
+	if want := (sql.NullInt64{Int64: 3, Valid: true}); got.Count != want {
+		t.Errorf("Merge() Count = %+v, want %+v", got.Count, want)
+	}
+}
+
+func TestSurfaceRegisteredBool(t *testing.T) {
+	type Si bool
+	typ := reflect.TypeOf(Si(false))
+	t.Cleanup(func() { smap.RegisterConverter(typ, nil) })
+	smap.RegisterConverter(typ, func(dst interface{}, s string) error {
+		if s != "si" {
+			return fmt.Errorf("not si: %q", s)
+		}
+		*dst.(*Si) = true
+		return nil
+	})
+	type Record struct {
+		Flag    Si  `smap:"EV.Value,hydrate"`
+		FlagPtr *Si `smap:"EV.Value,hydrate"`
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    Si
+		wantErr error
+	}{
+		{"converter", "si", true, nil},
+		{"token_fallback", "yes", true, nil},
+		{"both_fail", "maybe", false, smap.ErrBoolInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Record
+			err := smap.Merge(&got, Sources{EV: &EnvVars{Value: tt.value}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Flag != tt.want || got.FlagPtr == nil || *got.FlagPtr != tt.want {
+				t.Errorf("Merge() dst = %v, %v, want %v for both", got.Flag, got.FlagPtr, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceMergeRegexp(t *testing.T) {
+	type Patterns struct {
+		Name  *regexp.Regexp `smap:"EV.Value,hydrate"`
+		POSIX *regexp.Regexp `smap:"EV.AISvcKey,hydrate,posix"`
+	}
+
+	t.Run("compiles", func(t *testing.T) {
+		var got Patterns
+		src := Sources{EV: &EnvVars{Value: `^svc-\d+$`, AISvcKey: `a+|ab`}}
+		if err := smap.Merge(&got, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if got.Name == nil || !got.Name.MatchString("svc-12") || got.Name.MatchString("svc-x") {
+			t.Errorf("Merge() Name = %v, want pattern matching svc-12 only", got.Name)
+		}
+		if got.POSIX == nil || got.POSIX.FindString("ab") != "ab" {
+			t.Errorf("Merge() POSIX = %v, want leftmost-longest match %q", got.POSIX, "ab")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var got Patterns
+		err := smap.Merge(&got, Sources{EV: &EnvVars{Value: `(`}})
+		var fieldErr *smap.MergeFieldError
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("Merge() error = %v, want *MergeFieldError", err)
+		}
+		var syntaxErr *syntax.Error
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Merge() error = %v, want wrapped *syntax.Error", err)
+		}
+	})
+}
+
+func TestSurfaceMergeInlineStructs(t *testing.T) {
+	type Inline struct {
+		DB struct {
+			Host string
+			Port int
+		}
+	}
+	src := Inline{}
+	src.DB.Host = "db.local"
+	src.DB.Port = 5432
+
+	t.Run("navigates", func(t *testing.T) {
+		var got struct {
+			Host string `smap:"DB.Host"`
+			Port int    `smap:"DB.Port"`
+		}
+		if err := smap.Merge(&got, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if got.Host != "db.local" || got.Port != 5432 {
+			t.Errorf("Merge() got = %+v, want host db.local and port 5432", got)
+		}
+	})
+
+	t.Run("whole_inline_struct", func(t *testing.T) {
+		var got struct {
+			DB struct {
+				Host string
+				Port int
+			} `smap:"DB"`
+		}
+		if err := smap.Merge(&got, &src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if got.DB != src.DB {
+			t.Errorf("Merge() DB = %+v, want %+v", got.DB, src.DB)
+		}
+	})
+
+	t.Run("type_names_in_errors", func(t *testing.T) {
+		var got struct {
+			DB struct{ Host int } `smap:"DB"`
+		}
+		err := smap.Merge(&got, src)
+		var fieldErr *smap.MergeFieldError
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("Merge() error = %v, want *MergeFieldError", err)
+		}
+		if want := "struct { Host int }"; fieldErr.DstTypeName != want {
+			t.Errorf("MergeFieldError.DstTypeName = %q, want %q", fieldErr.DstTypeName, want)
+		}
+		if want := "struct { Host string; Port int }"; fieldErr.SrcTypeName != want {
+			t.Errorf("MergeFieldError.SrcTypeName = %q, want %q", fieldErr.SrcTypeName, want)
+		}
+	})
+}
+
+func TestSurfaceMergePointerLeaf(t *testing.T) {
+	t.Run("pointer_assigned_as_is", func(t *testing.T) {
+		url := "env-url"
+		dst := ConfigPointer{}
+		if err := smap.Merge(&dst, Sources{EV: &EnvVars{URL: &url}}); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if dst.URL != &url {
+			t.Errorf("Merge() URL = %p, want source pointer %p", dst.URL, &url)
+		}
+	})
+
+	t.Run("pointer_to_zero_nilonzero", func(t *testing.T) {
+		type Record struct {
+			URL *string `smap:"EV.URL,nilonzero"`
+		}
+		empty := ""
+		dst := Record{}
+		if err := smap.Merge(&dst, Sources{EV: &EnvVars{URL: &empty}}); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if dst.URL != nil {
+			t.Errorf("Merge() URL = %q, want nil", *dst.URL)
+		}
+
+		unresolved, err := smap.MergeWithResult(&dst, Sources{EV: &EnvVars{URL: &empty}})
+		if err != nil || !reflect.DeepEqual(unresolved, []string{"URL"}) {
+			t.Errorf("MergeWithResult() = %q, %v, want [URL], nil", unresolved, err)
+		}
+	})
+
+	t.Run("pointer_to_zero_skipzero", func(t *testing.T) {
+		type Record struct {
+			URL *string `smap:"FV.Service.URL|EV.URL,skipzero"`
+		}
+		url, empty := "file-url", ""
+		dst := Record{}
+		src := Sources{
+			EV: &EnvVars{URL: &empty},
+			FV: &FileVals{Service: FileValsService{URL: &url}},
+		}
+		if err := smap.Merge(&dst, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if dst.URL != &url {
+			t.Errorf("Merge() URL = %v, want file pointer %p", dst.URL, &url)
+		}
+	})
+}
+
+func TestSurfaceMerger(t *testing.T) {
+	src := Sources{
+		EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"},
+	}
+	m := smap.NewMerger(smap.WithOptions(nil, smap.WithOptions()))
+
+	for i := 0; i < 2; i++ {
+		dst := &Config{}
+		if err := m.Merge(dst, src); err != nil {
+			t.Fatalf("Merger.Merge() error = %v, want nil", err)
+		}
+		want := Config{AISvcURL: "env-url", AISvcKey: "env-key"}
+		if !reflect.DeepEqual(*dst, want) {
+			t.Errorf("Merger.Merge() dst = %+v, want %+v", *dst, want)
+		}
+	}
+}
+
+func TestSurfaceMergeWithResult(t *testing.T) {
+	type ConfigPartial struct {
+		URL     string  `smap:"EV.Nil.URL"`
+		Key     string  `smap:"EV.AISvcKey"`
+		Pointer *string `smap:"EV.URL"`
+	}
+	dst := &ConfigPartial{}
+	src := Sources{
+		EV: &EnvVars{AISvcKey: "env-key"},
+	}
+
+	unresolved, err := smap.MergeWithResult(dst, src)
+	if err != nil {
+		t.Fatalf("MergeWithResult() error = %v, want nil", err)
+	}
+	want := []string{"URL", "Pointer"}
+	if !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("MergeWithResult() unresolved = %v, want %v", unresolved, want)
+	}
+	if dst.Key != "env-key" {
+		t.Errorf("MergeWithResult() dst.Key = %q, want %q", dst.Key, "env-key")
+	}
+}
+
+func TestSurfaceTagKeyFor(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []smap.Option
+		want string
+	}{
+		{name: "default", opts: nil, want: smap.TagKey},
+		{name: "custom", opts: []smap.Option{smap.WithTagKey("cfg")}, want: "cfg"},
+		{name: "empty_ignored", opts: []smap.Option{smap.WithTagKey("")}, want: smap.TagKey},
+		{name: "last_wins", opts: []smap.Option{smap.WithTagKey("a"), smap.WithTagKey("b")}, want: "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := smap.TagKeyFor(tt.opts...); got != tt.want {
+				t.Errorf("TagKeyFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceTagKeyFallback(t *testing.T) {
+	type Migrating struct {
+		URL   string `smap:"EV.AISvcURL"`
+		Key   string `config:"EV.AISvcKey"`
+		Value string `smap:"EV.Data.nokey" config:"EV.Value"`
+		Other string
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key", Value: "env-value"}}
+	cache := smap.NewCache()
+
+	var plain Migrating
+	if err := smap.Merge(&plain, src, smap.WithCache(cache)); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if want := (Migrating{URL: "env-url"}); plain != want {
+		t.Errorf("Merge() dst = %+v, want %+v", plain, want)
+	}
+
+	var got Migrating
+	if err := smap.Merge(&got, src, smap.WithCache(cache), smap.WithTagKeyFallback("config")); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if want := (Migrating{URL: "env-url", Key: "env-key"}); got != want {
+		t.Errorf("Merge() with fallback dst = %+v, want %+v", got, want)
+	}
+
+	v, ok, err := smap.ResolvedValue(&Migrating{}, "Key", src, smap.WithTagKeyFallback("config"))
+	if err != nil || !ok || v != "env-key" {
+		t.Errorf("ResolvedValue() = %v, %v, %v, want env-key, true, nil", v, ok, err)
+	}
+}
+
+var errPortRange = errors.New("port out of range")
+
+// Helper to validate Port fields
+func validatePort(field string, v reflect.Value) error {
+	if field == "Port" && (v.Int() < 1 || v.Int() > 65535) {
+		return errPortRange
+	}
+	return nil
+}
+
+var errSecretInvalid = errors.New("invalid secret")
+
+// Helper to decrypt "enc:" prefixed AISvcKey values (by reversing them)
+func decryptSecret(field string, v reflect.Value) (reflect.Value, error) {
+	if field != "AISvcKey" {
+		return v, nil
+	}
+	secret := v.String()
+	if !strings.HasPrefix(secret, "enc:") {
+		return reflect.Value{}, errSecretInvalid
+	}
+	runes := []rune(secret[len("enc:"):])
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return reflect.ValueOf(string(runes)), nil
+}
+
+func TestSurfaceResolvedValue(t *testing.T) {
+	src := Sources{
+		EV: &EnvVars{AISvcKey: "env-key", Count: 42},
+	}
+
+	tests := []struct {
+		name      string
+		dst       interface{}
+		field     string
+		want      interface{}
+		wantFound bool
+		wantErr   error
+	}{
+		{name: "resolved", dst: &Config{}, field: "AISvcKey", want: "env-key", wantFound: true},
+		{name: "hydrated", dst: &ConfigHydrate{}, field: "Count", want: 42, wantFound: true},
+		{name: "unresolved", dst: &ConfigNilPath{}, field: "NilPath", want: nil, wantFound: false},
+		{name: "untagged", dst: &Config{}, field: "NoTag", wantErr: smap.ErrFieldNotFound},
+		{name: "missing", dst: &Config{}, field: "Missing", wantErr: smap.ErrFieldNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := reflect.ValueOf(tt.dst).Elem().Interface()
+			got, found, err := smap.ResolvedValue(tt.dst, tt.field, src)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolvedValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if found != tt.wantFound || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolvedValue() = (%v, %v), want (%v, %v)", got, found, tt.want, tt.wantFound)
+			}
+			if after := reflect.ValueOf(tt.dst).Elem().Interface(); !reflect.DeepEqual(before, after) {
+				t.Errorf("ResolvedValue() modified dst: %+v", after)
+			}
+		})
+	}
+}
+
+func TestSurfaceMergeSlice(t *testing.T) {
+	srcs := []EnvVars{
+		{AISvcKey: "key-0", Count: 1},
+		{AISvcKey: "key-1", Count: 2},
+	}
+	type Record struct {
+		Key   string `smap:"AISvcKey"`
+		Count int    `smap:"Count"`
+	}
+
+	t.Run("struct_elements", func(t *testing.T) {
+		dsts := make([]Record, 2)
+		if err := smap.MergeSlice(dsts, srcs); err != nil {
+			t.Fatalf("MergeSlice() error = %v, want nil", err)
+		}
+		want := []Record{{Key: "key-0", Count: 1}, {Key: "key-1", Count: 2}}
+		if !reflect.DeepEqual(dsts, want) {
+			t.Errorf("MergeSlice() dsts = %+v, want %+v", dsts, want)
+		}
+	})
+
+	t.Run("pointer_elements", func(t *testing.T) {
+		dsts := []*Record{{}, {}}
+		if err := smap.MergeSlice(dsts, []*EnvVars{&srcs[0], &srcs[1]}); err != nil {
+			t.Fatalf("MergeSlice() error = %v, want nil", err)
+		}
+		if dsts[1].Key != "key-1" {
+			t.Errorf("MergeSlice() dsts[1].Key = %q, want %q", dsts[1].Key, "key-1")
+		}
+	})
+
+	t.Run("length_mismatch", func(t *testing.T) {
+		dsts := make([]Record, 1)
+		if err := smap.MergeSlice(dsts, srcs); !errors.Is(err, smap.ErrLengthMismatch) {
+			t.Fatalf("MergeSlice() error = %v, want %v", err, smap.ErrLengthMismatch)
+		}
+		if dsts[0] != (Record{}) {
+			t.Errorf("MergeSlice() modified dsts on length mismatch: %+v", dsts)
+		}
+	})
+
+	t.Run("nil_source_element_ok", func(t *testing.T) {
+		dsts := []Record{{Key: "keep"}, {}}
+		if err := smap.MergeSlice(dsts, []*EnvVars{nil, &srcs[1]}, smap.WithNilSourceOK()); err != nil {
+			t.Fatalf("MergeSlice() error = %v, want nil", err)
+		}
+		want := []Record{{Key: "keep"}, {Key: "key-1", Count: 2}}
+		if !reflect.DeepEqual(dsts, want) {
+			t.Errorf("MergeSlice() dsts = %+v, want %+v", dsts, want)
+		}
+	})
+
+	t.Run("nil_pointer_element", func(t *testing.T) {
+		dsts := []*Record{{}, nil}
+		if err := smap.MergeSlice(dsts, srcs); !errors.Is(err, smap.ErrDstInvalid) {
+			t.Fatalf("MergeSlice() error = %v, want %v", err, smap.ErrDstInvalid)
+		}
+	})
+}
+
+func TestSurfaceMergeAddressable(t *testing.T) {
+	type Record struct {
+		Key   string `smap:"AISvcKey"`
+		Count int    `smap:"Count"`
+	}
+	src := &EnvVars{AISvcKey: "env-key", Count: 2}
+
+	t.Run("slice_element", func(t *testing.T) {
+		dsts := reflect.ValueOf(make([]Record, 2))
+		if err := smap.MergeAddressable(dsts.Index(1), src); err != nil {
+			t.Fatalf("MergeAddressable() error = %v, want nil", err)
+		}
+		want := []Record{{}, {Key: "env-key", Count: 2}}
+		if got := dsts.Interface(); !reflect.DeepEqual(got, want) {
+			t.Errorf("MergeAddressable() dsts = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		var dst Record
+		if err := smap.MergeAddressable(reflect.ValueOf(&dst), src); err != nil {
+			t.Fatalf("MergeAddressable() error = %v, want nil", err)
+		}
+		if want := (Record{Key: "env-key", Count: 2}); dst != want {
+			t.Errorf("MergeAddressable() dst = %+v, want %+v", dst, want)
+		}
+	})
+
+	t.Run("not_settable", func(t *testing.T) {
+		err := smap.MergeAddressable(reflect.ValueOf(Record{}), src)
+		if !errors.Is(err, smap.ErrDstInvalid) {
+			t.Fatalf("MergeAddressable() error = %v, want %v", err, smap.ErrDstInvalid)
+		}
+	})
+
+	t.Run("nil_source_ok", func(t *testing.T) {
+		dst := Record{Key: "keep"}
+		err := smap.MergeAddressable(reflect.ValueOf(&dst).Elem(), (*EnvVars)(nil), smap.WithNilSourceOK())
+		if err != nil {
+			t.Fatalf("MergeAddressable() error = %v, want nil", err)
+		}
+		if dst.Key != "keep" {
+			t.Errorf("MergeAddressable() Key = %q, want %q", dst.Key, "keep")
+		}
+	})
+}
+
+func TestSurfaceMergeSliceElems(t *testing.T) {
+	srcs := []EnvVars{
+		{AISvcKey: "key-0", Count: 1},
+		{AISvcKey: "key-1", Count: 2},
+	}
+	type Record struct {
+		Key   string `smap:"AISvcKey"`
+		Count int    `smap:"Count"`
+		Note  string
+	}
+
+	t.Run("grows_struct_elements", func(t *testing.T) {
+		dsts := []Record{{Note: "keep"}}
+		if err := smap.MergeSliceElems(&dsts, srcs); err != nil {
+			t.Fatalf("MergeSliceElems() error = %v, want nil", err)
+		}
+		want := []Record{{Key: "key-0", Count: 1, Note: "keep"}, {Key: "key-1", Count: 2}}
+		if !reflect.DeepEqual(dsts, want) {
+			t.Errorf("MergeSliceElems() dsts = %+v, want %+v", dsts, want)
+		}
+	})
+
+	t.Run("grows_pointer_elements", func(t *testing.T) {
+		var dsts []*Record
+		if err := smap.MergeSliceElems(&dsts, &srcs); err != nil {
+			t.Fatalf("MergeSliceElems() error = %v, want nil", err)
+		}
+		if len(dsts) != 2 || dsts[1] == nil || dsts[1].Key != "key-1" {
+			t.Errorf("MergeSliceElems() dsts = %+v, want 2 merged elements", dsts)
+		}
+	})
+
+	t.Run("keeps_extra_elements", func(t *testing.T) {
+		dsts := []Record{{}, {}, {Note: "extra"}}
+		if err := smap.MergeSliceElems(&dsts, srcs); err != nil {
+			t.Fatalf("MergeSliceElems() error = %v, want nil", err)
+		}
+		want := []Record{{Key: "key-0", Count: 1}, {Key: "key-1", Count: 2}, {Note: "extra"}}
+		if !reflect.DeepEqual(dsts, want) {
+			t.Errorf("MergeSliceElems() dsts = %+v, want %+v", dsts, want)
+		}
+	})
+
+	t.Run("nil_source_element_ok", func(t *testing.T) {
+		var dsts []Record
+		if err := smap.MergeSliceElems(&dsts, []*EnvVars{nil, &srcs[1]}, smap.WithNilSourceOK()); err != nil {
+			t.Fatalf("MergeSliceElems() error = %v, want nil", err)
+		}
+		want := []Record{{}, {Key: "key-1", Count: 2}}
+		if !reflect.DeepEqual(dsts, want) {
+			t.Errorf("MergeSliceElems() dsts = %+v, want %+v", dsts, want)
+		}
+	})
+
+	t.Run("nil_source_element_keeps_nil_pointer", func(t *testing.T) {
+		var dsts []*Record
+		if err := smap.MergeSliceElems(&dsts, []*EnvVars{nil, &srcs[1]}, smap.WithNilSourceOK()); err != nil {
+			t.Fatalf("MergeSliceElems() error = %v, want nil", err)
+		}
+		if len(dsts) != 2 || dsts[0] != nil || dsts[1] == nil || dsts[1].Key != "key-1" {
+			t.Errorf("MergeSliceElems() dsts = %+v, want a nil first element", dsts)
+		}
+	})
+
+	t.Run("nil_source_element", func(t *testing.T) {
+		var dsts []*Record
+		err := smap.MergeSliceElems(&dsts, []*EnvVars{nil})
+		if !errors.Is(err, smap.ErrSrcNil) {
+			t.Fatalf("MergeSliceElems() error = %v, want %v", err, smap.ErrSrcNil)
+		}
+		if len(dsts) != 1 || dsts[0] != nil {
+			t.Errorf("MergeSliceElems() dsts = %+v, want a nil element", dsts)
+		}
+	})
+
+	t.Run("dst_not_slice_pointer", func(t *testing.T) {
+		dsts := []Record{}
+		if err := smap.MergeSliceElems(dsts, srcs); !errors.Is(err, smap.ErrDstInvalid) {
+			t.Fatalf("MergeSliceElems() error = %v, want %v", err, smap.ErrDstInvalid)
+		}
+	})
+
+	t.Run("src_not_indexed", func(t *testing.T) {
+		var dsts []Record
+		if err := smap.MergeSliceElems(&dsts, srcs[0]); !errors.Is(err, smap.ErrSrcInvalid) {
+			t.Fatalf("MergeSliceElems() error = %v, want %v", err, smap.ErrSrcInvalid)
+		}
+	})
+}
+
+func TestSurfaceMergeReader(t *testing.T) {
+	type Record struct {
+		Host  string  `smap:"server.host"`
+		Port  float64 `smap:"server.port"`
+		First string  `smap:"users.0"`
+	}
+	doc := `{"server": {"host": "localhost", "port": 8080}, "users": ["alice", "bob"]}`
+
+	t.Run("json", func(t *testing.T) {
+		var got Record
+		if err := smap.MergeReader(&got, strings.NewReader(doc), "json"); err != nil {
+			t.Fatalf("MergeReader() error = %v, want nil", err)
+		}
+		want := Record{Host: "localhost", Port: 8080, First: "alice"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("MergeReader() got = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("json_numbers", func(t *testing.T) {
+		type Numbers struct {
+			Port    int     `smap:"server.port"`
+			PortPtr *uint16 `smap:"server.port"`
+			Ratio   float32 `smap:"ratio"`
+		}
+		var got Numbers
+		err := smap.MergeReader(&got, strings.NewReader(`{"server": {"port": 8080}, "ratio": 0.5}`), "json")
+		if err != nil {
+			t.Fatalf("MergeReader() error = %v, want nil", err)
+		}
+		if got.Port != 8080 || got.PortPtr == nil || *got.PortPtr != 8080 || got.Ratio != 0.5 {
+			t.Errorf("MergeReader() got = %+v, want port 8080 and ratio 0.5", got)
+		}
+	})
+
+	t.Run("json_number_overflow", func(t *testing.T) {
+		var got struct {
+			Port int8 `smap:"server.port"`
+		}
+		err := smap.MergeReader(&got, strings.NewReader(doc), "json")
+		if !errors.Is(err, smap.ErrNumberOverflow) {
+			t.Fatalf("MergeReader() error = %v, want %v", err, smap.ErrNumberOverflow)
+		}
+		if !errors.Is(err, smap.ErrValueOverflow) {
+			t.Errorf("MergeReader() error = %v, want %v", err, smap.ErrValueOverflow)
+		}
+	})
+
+	t.Run("json_number_fraction", func(t *testing.T) {
+		var got struct {
+			Ratio int `smap:"ratio"`
+		}
+		err := smap.MergeReader(&got, strings.NewReader(`{"ratio": 0.5}`), "json")
+		var fieldErr *smap.MergeFieldError
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("MergeReader() error = %v, want *MergeFieldError", err)
+		}
+	})
+
+	t.Run("registered_decoder", func(t *testing.T) {
+		smap.RegisterDecoder("kv", func(data []byte) (map[string]interface{}, error) {
+			host, _ := strconv.Unquote(string(data))
+			return map[string]interface{}{
+				"server": map[string]interface{}{"host": host},
+			}, nil
+		})
+		var got Record
+		if err := smap.MergeReader(&got, strings.NewReader(`"example.com"`), "kv"); err != nil {
+			t.Fatalf("MergeReader() error = %v, want nil", err)
+		}
+		if got.Host != "example.com" {
+			t.Errorf("MergeReader() Host = %q, want %q", got.Host, "example.com")
+		}
+	})
+
+	t.Run("unknown_format", func(t *testing.T) {
+		var got Record
+		err := smap.MergeReader(&got, strings.NewReader(doc), "toml")
+		if !errors.Is(err, smap.ErrFormatUnknown) {
+			t.Fatalf("MergeReader() error = %v, want %v", err, smap.ErrFormatUnknown)
+		}
+	})
+
+	t.Run("decode_error", func(t *testing.T) {
+		var got Record
+		if err := smap.MergeReader(&got, strings.NewReader("{"), "json"); err == nil {
+			t.Fatal("MergeReader() error = nil, want decode error")
+		}
+	})
+}
+
+func TestSurfaceCache(t *testing.T) {
+	cache := smap.NewCache()
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"}}
+	mergers := []struct {
+		merger *smap.Merger
+		want   ConfigTagKey
+	}{
+		{smap.NewMerger(smap.WithCache(cache)), ConfigTagKey{URL: "env-url"}},
+		{smap.NewMerger(smap.WithCache(cache), smap.WithTagKey("cfg")), ConfigTagKey{Key: "env-key"}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		for _, m := range mergers {
+			wg.Add(1)
+			go func(merger *smap.Merger, want ConfigTagKey) {
+				defer wg.Done()
+				var got ConfigTagKey
+				if err := merger.Merge(&got, src); err != nil {
+					t.Errorf("Merge() error = %v, want nil", err)
+				}
+				if got != want {
+					t.Errorf("Merge() got = %+v, want %+v", got, want)
+				}
+			}(m.merger, m.want)
+		}
+	}
+	wg.Wait()
+
+	cache.Reset()
+	var got ConfigTagKey
+	if err := mergers[0].merger.Merge(&got, src); err != nil {
+		t.Fatalf("Merge() after Reset error = %v, want nil", err)
+	}
+	if got != mergers[0].want {
+		t.Errorf("Merge() after Reset got = %+v, want %+v", got, mergers[0].want)
+	}
+
+	var invalid struct {
+		URL string `smap:"EV..AISvcURL"`
+	}
+	for i := 0; i < 2; i++ {
+		err := mergers[0].merger.Merge(&invalid, src)
+		if !errors.Is(err, smap.ErrTagInvalid) {
+			t.Errorf("Merge() invalid tag (run %d) error = %v, want %v", i, err, smap.ErrTagInvalid)
+		}
+	}
+}
+
+func TestSurfacePreValidate(t *testing.T) {
+	type Record struct {
+		URL string `smap:"EV.AISvcURL"`
+		Key string `smap:"EV..AISvcKey"`
+	}
+	type SetterRecord struct {
+		URL  string   `smap:"EV.AISvcURL"`
+		Port struct{} `smap:"EV.Value,setter=SetMissing"`
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"}}
+
+	tests := []struct {
+		name    string
+		dst     interface{}
+		opts    []smap.Option
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name:    "default_merges_until_bad_tag",
+			dst:     &Record{},
+			want:    Record{URL: "env-url"},
+			wantErr: smap.ErrTagInvalid,
+		},
+		{
+			name:    "prevalidate_bad_tag",
+			dst:     &Record{},
+			opts:    []smap.Option{smap.WithPreValidate()},
+			want:    Record{},
+			wantErr: smap.ErrTagInvalid,
+		},
+		{
+			name:    "prevalidate_bad_setter",
+			dst:     &SetterRecord{},
+			opts:    []smap.Option{smap.WithPreValidate()},
+			want:    SetterRecord{},
+			wantErr: smap.ErrSetterInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := smap.Merge(tt.dst, src, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := reflect.ValueOf(tt.dst).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() dst = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceFallbackEnv(t *testing.T) {
+	t.Setenv("SMAP_TEST_URL", "env-url")
+	t.Setenv("Count", "12")
+	type FallbackEnv struct {
+		URL   string `smap:"EV.AISvcURL,fallbackenv=SMAP_TEST_URL"`
+		Count int    `smap:"FV.Count|EV.Count,skipzero,fallbackenv,hydrate"`
+		Key   string `smap:"EV.AISvcKey,fallbackenv=SMAP_TEST_UNSET"`
+	}
+
+	t.Run("paths_unresolved", func(t *testing.T) {
+		var got FallbackEnv
+		if err := smap.Merge(&got, Sources{EV: &EnvVars{}}, smap.WithZeroAsAbsent()); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		want := FallbackEnv{URL: "env-url", Count: 12}
+		if got != want {
+			t.Errorf("Merge() got = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("paths_resolved", func(t *testing.T) {
+		var got FallbackEnv
+		src := Sources{EV: &EnvVars{AISvcURL: "src-url", AISvcKey: "src-key", Count: 3}}
+		if err := smap.Merge(&got, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		want := FallbackEnv{URL: "src-url", Count: 3, Key: "src-key"}
+		if got != want {
+			t.Errorf("Merge() got = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestSurfaceResolvedInto(t *testing.T) {
+	type Snapshot struct {
+		URL   string `smap:"EV.AISvcURL|FV.Service.URL"`
+		Count int    `smap:"EV.Value,hydrate"`
+		Key   string `smap:"EV.Missing,onerror=skip"`
+	}
+	src := Sources{
+		EV: &EnvVars{AISvcURL: "env-url", Value: "42"},
+		FV: &FileVals{Service: FileValsService{URL: strPtr("file-url")}},
+	}
+
+	for _, n := range []int{1, 3} {
+		t.Run(fmt.Sprintf("concurrent_fields_%d", n), func(t *testing.T) {
+			resolved := map[string]interface{}{}
+			var dst Snapshot
+			err := smap.Merge(&dst, src, smap.WithResolvedInto(resolved), smap.WithConcurrentFields(n))
+			if err != nil {
+				t.Fatalf("Merge() error = %v, want nil", err)
+			}
+			want := map[string]interface{}{"URL": "file-url", "Count": "42"}
+			if !reflect.DeepEqual(resolved, want) {
+				t.Errorf("Merge() resolved = %v, want %v", resolved, want)
+			}
+		})
+	}
+}
+
+func TestSurfaceMergeMapValues(t *testing.T) {
+	type Backend struct {
+		Host   string `smap:"Host"`
+		Port   int    `smap:"Port,skipzero"`
+		Weight int
+	}
+	src := Sources{EV: &EnvVars{Backends: map[string]*Server{
+		"a": {Host: "a.local", Port: 80},
+		"b": {Host: "b.local"},
+		"c": nil,
+		"x": {Host: "x.local", Port: 8080},
+	}}}
+
+	t.Run("struct_values", func(t *testing.T) {
+		type Record struct {
+			Backends map[string]Backend `smap:"EV.Backends,merge"`
+		}
+		dst := Record{Backends: map[string]Backend{
+			"a": {Host: "old", Weight: 5},
+			"b": {Host: "old", Port: 443},
+			"c": {Host: "keep"},
+			"d": {Host: "keep"},
+		}}
+		if err := smap.Merge(&dst, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		want := map[string]Backend{
+			"a": {Host: "a.local", Port: 80, Weight: 5},
+			"b": {Host: "b.local", Port: 443},
+			"c": {Host: "keep"},
+			"d": {Host: "keep"},
+		}
+		if !reflect.DeepEqual(dst.Backends, want) {
+			t.Errorf("Merge() Backends = %+v, want %+v", dst.Backends, want)
+		}
+	})
+
+	t.Run("pointer_values", func(t *testing.T) {
+		type Record struct {
+			Backends map[string]*Backend `smap:"EV.Backends,merge"`
+		}
+		a := &Backend{Host: "old", Weight: 5}
+		dst := Record{Backends: map[string]*Backend{"a": a}}
+		if err := smap.Merge(&dst, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		if want := (Backend{Host: "a.local", Port: 80, Weight: 5}); dst.Backends["a"] != a || *a != want {
+			t.Errorf("Merge() Backends[a] = %+v, want %+v merged in place", dst.Backends["a"], want)
+		}
+	})
+
+	t.Run("nil_destination_allocated", func(t *testing.T) {
+		type Record struct {
+			Backends map[string]*Backend `smap:"EV.Backends,merge"`
+		}
+		var dst Record
+		if err := smap.Merge(&dst, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+		want := map[string]*Backend{
+			"a": {Host: "a.local", Port: 80},
+			"b": {Host: "b.local"},
+			"x": {Host: "x.local", Port: 8080},
+		}
+		if !reflect.DeepEqual(dst.Backends, want) {
+			t.Errorf("Merge() Backends = %+v, want %+v", dst.Backends, want)
+		}
+	})
+
+	t.Run("hooks_see_merged_map", func(t *testing.T) {
+		type Record struct {
+			Backends map[string]*Backend `smap:"EV.Backends,merge"`
+		}
+		var validated []string
+		validate := func(field string, v reflect.Value) error {
+			validated = append(validated, field)
+			return nil
+		}
+		veto := func(field string, _, _ reflect.Value) bool {
+			return field != "Backends"
+		}
+		a := &Backend{Host: "old"}
+		dst := Record{Backends: map[string]*Backend{"a": a}}
+		unresolved, err := smap.MergeWithResult(&dst, src, smap.WithValueValidator(validate), smap.WithAssignHook(veto))
+		if err != nil {
+			t.Fatalf("MergeWithResult() error = %v, want nil", err)
+		}
+		if want := []string{"Host", "Port", "Backends"}; !reflect.DeepEqual(validated, want) {
+			t.Errorf("WithValueValidator() fields = %v, want %v", validated, want)
+		}
+		if !reflect.DeepEqual(unresolved, []string{"Backends"}) || *a != (Backend{Host: "old"}) {
+			t.Errorf("MergeWithResult() = %v, Backends[a] = %+v, want vetoed and untouched", unresolved, *a)
+		}
+	})
+
+	t.Run("nothing_written", func(t *testing.T) {
+		type Record struct {
+			Backends map[string]Backend `smap:"EV.Backends,merge"`
+		}
+		dst := Record{Backends: map[string]Backend{"z": {Host: "keep"}}}
+		unresolved, err := smap.MergeWithResult(&dst, src)
+		if err != nil {
+			t.Fatalf("MergeWithResult() error = %v, want nil", err)
+		}
+		if !reflect.DeepEqual(unresolved, []string{"Backends"}) {
+			t.Errorf("MergeWithResult() unresolved = %v, want [Backends]", unresolved)
+		}
+	})
+
+	t.Run("without_merge_option", func(t *testing.T) {
+		type Record struct {
+			Backends map[string]Backend `smap:"EV.Backends"`
+		}
+		err := smap.Merge(&Record{}, src)
+		if !errors.Is(err, smap.ErrFieldTypesIncompatible) {
+			t.Fatalf("Merge() error = %v, want %v", err, smap.ErrFieldTypesIncompatible)
+		}
+	})
+
+	t.Run("nested_field_error", func(t *testing.T) {
+		type Strict struct {
+			Port string `smap:"Port"`
+		}
+		type Record struct {
+			Backends map[string]Strict `smap:"EV.Backends,merge"`
+		}
+		dst := Record{Backends: map[string]Strict{"a": {}}}
+		err := smap.Merge(&dst, src)
+		if !errors.Is(err, smap.ErrFieldTypesIncompatible) {
+			t.Fatalf("Merge() error = %v, want %v", err, smap.ErrFieldTypesIncompatible)
+		}
+		if fieldErrs := smap.FieldErrors(err); len(fieldErrs) != 1 || fieldErrs[0].TagValue != "EV.Backends,merge" {
+			t.Errorf("FieldErrors() = %v, want one error for the map field", fieldErrs)
+		}
+	})
+}
+
+func TestSurfaceUnknownPaths(t *testing.T) {
+	type Record struct {
+		URL     string `smap:"FV.Service.URL|EV.AISvcURL"`
+		Key     string `smap:"EV.Missing,onerror=skip"`
+		Data    string `smap:"EV.Data.nokey"`
+		NilPath string `smap:"EV.Nil.URL"`
+		Renamed string `smap:"EV.OldKey"`
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key", Data: map[string]string{"key": "value"}}}
+
+	var got []string
+	report := func(field, path, failedSegment string) {
+		got = append(got, field+": "+path+" @ "+failedSegment)
+	}
+	var dst Record
+	err := smap.Merge(&dst, src,
+		smap.WithUnknownPaths(report),
+		smap.WithRenamePaths(map[string]string{"EV.OldKey": "EV.AISvcKey"}),
+	)
+	if err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	want := []string{
+		"URL: FV.Service.URL @ FV",
+		"Key: EV.Missing @ Missing",
+		"Data: EV.Data.nokey @ nokey",
+		"NilPath: EV.Nil.URL @ Nil",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithUnknownPaths() reports = %q, want %q", got, want)
+	}
+	if wantDst := (Record{URL: "env-url", Renamed: "env-key"}); dst != wantDst {
+		t.Errorf("Merge() dst = %+v, want %+v", dst, wantDst)
+	}
+}
+
+func TestSurfaceQuotedSegments(t *testing.T) {
+	type Record struct {
+		Comma  string `smap:"EV.Data.\"a,b\",skipzero"`
+		Dot    string `smap:"EV.Data.key|EV.Data.\"a.b\""`
+		Absent string `smap:"EV.Data.\"x,y\"|EV.Data.key"`
+	}
+	src := Sources{EV: &EnvVars{Data: map[string]string{"a,b": "comma", "a.b": "dot", "key": "plain", "a": "wrong"}}}
+
+	var dst Record
+	if err := smap.Merge(&dst, src); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if want := (Record{Comma: "comma", Dot: "dot", Absent: "plain"}); dst != want {
+		t.Errorf("Merge() dst = %+v, want %+v", dst, want)
+	}
+}
+
+func TestSurfaceQuotedSegmentsRenamed(t *testing.T) {
+	type Record struct {
+		Renamed string `smap:"EV.OldData.\"a.b\""`
+		Keyed   string `smap:"EV.\"x.y\""`
+		Missing string `smap:"EV.Data.\"c.d\""`
+	}
+	src := Sources{EV: &EnvVars{Data: map[string]string{"a,b": "comma", "a.b": "dot", "a": "wrong"}}}
+
+	var got []string
+	report := func(field, path, failedSegment string) {
+		got = append(got, field+": "+path+" @ "+failedSegment)
+	}
+	var dst Record
+	err := smap.Merge(&dst, src,
+		smap.WithUnknownPaths(report),
+		smap.WithRenamePaths(map[string]string{
+			"EV.OldData":   "EV.Data",
+			`EV."x.y"`:     `EV.Data."a,b"`,
+			`EV.OldData.a`: "EV.Data.a",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if want := (Record{Renamed: "dot", Keyed: "comma"}); dst != want {
+		t.Errorf("Merge() dst = %+v, want %+v", dst, want)
+	}
+	if want := []string{`Missing: EV.Data."c.d" @ c.d`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WithUnknownPaths() reports = %q, want %q", got, want)
+	}
+	if out := smap.Inspect(&dst); !strings.Contains(out, `path: EV.Data."c.d"`) {
+		t.Errorf("Inspect() = %q, want quoted segment", out)
+	}
+}
+
+func TestSurfaceInterfacePassthrough(t *testing.T) {
+	type Record struct {
+		Str     interface{} `smap:"EV.AISvcKey,hydrate"`
+		Int     interface{} `smap:"EV.Count"`
+		Struct  interface{} `smap:"EV.Labels"`
+		Missing interface{} `smap:"EV.Data.nokey"`
+	}
+	src := Sources{EV: &EnvVars{AISvcKey: "8080", Count: 7, Labels: Labels{Team: "core"}}}
+
+	dst := Record{Missing: "kept"}
+	if err := smap.Merge(&dst, src, smap.WithDefaultHydrate()); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	want := Record{Str: "8080", Int: 7, Struct: Labels{Team: "core"}, Missing: "kept"}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("Merge() dst = %#v, want %#v", dst, want)
+	}
+
+	type Options struct {
+		JSON    interface{} `smap:"EV.AISvcKey,json"`
+		Trimmed interface{} `smap:"EV.AISvcURL,trim"`
+	}
+	src = Sources{EV: &EnvVars{AISvcKey: `{"port":8080}`, AISvcURL: "  env-url  "}}
+
+	var opts Options
+	if err := smap.Merge(&opts, src, smap.WithDefaultHydrate()); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	wantOpts := Options{JSON: map[string]interface{}{"port": float64(8080)}, Trimmed: "env-url"}
+	if !reflect.DeepEqual(opts, wantOpts) {
+		t.Errorf("Merge() dst = %#v, want %#v", opts, wantOpts)
+	}
+}
+
+func TestSurfaceHydrateMapKeys(t *testing.T) {
+	type Ports struct {
+		Names map[int]string `smap:"EV.Data"`
+	}
+	type Gathered struct {
+		Names map[uint16]string `smap:"EV.Data.*"`
+	}
+	type Weights struct {
+		Names map[float64]string `smap:"EV.Data"`
+	}
+	type Small struct {
+		Names map[int8]string `smap:"EV.Data"`
+	}
+
+	tests := []struct {
+		name    string
+		dst     interface{}
+		data    map[string]string
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "int_keys",
+			dst:  &Ports{},
+			data: map[string]string{"80": "http", "443": "https"},
+			want: &Ports{Names: map[int]string{80: "http", 443: "https"}},
+		},
+		{
+			name: "gathered_uint_keys",
+			dst:  &Gathered{},
+			data: map[string]string{"80": "http"},
+			want: &Gathered{Names: map[uint16]string{80: "http"}},
+		},
+		{
+			name: "float_keys",
+			dst:  &Weights{},
+			data: map[string]string{"0.5": "half"},
+			want: &Weights{Names: map[float64]string{0.5: "half"}},
+		},
+		{
+			name:    "unparsable_key",
+			dst:     &Ports{},
+			data:    map[string]string{"http": "80"},
+			want:    &Ports{},
+			wantErr: smap.ErrMapKeyInvalid,
+		},
+		{
+			name:    "overflowing_key",
+			dst:     &Small{},
+			data:    map[string]string{"300": "big"},
+			want:    &Small{},
+			wantErr: smap.ErrMapKeyInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := smap.Merge(tt.dst, Sources{EV: &EnvVars{Data: tt.data}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("Merge() dst = %+v, want %+v", tt.dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceProfiling(t *testing.T) {
+	type Record struct {
+		Value string   `smap:"EV.AISvcURL|EV.GetValue"`
+		Count int      `smap:"EV.Count"`
+		Hosts []string `smap:"EV.Servers.#.Host"`
+	}
+	src := Sources{EV: &EnvVars{Count: 3, Servers: []Server{{Host: "a"}, {Host: "b"}}}}
+
+	var stats smap.Stats
+	m := smap.NewMerger(smap.WithProfiling(&stats), smap.WithCache(smap.NewCache()))
+	for i := 0; i < 2; i++ {
+		if err := m.Merge(&Record{}, src); err != nil {
+			t.Fatalf("Merge() error = %v, want nil", err)
+		}
+	}
+
+	got := map[string]int64{
+		"PathAttempts": stats.PathAttempts.Load(),
+		"MethodCalls":  stats.MethodCalls.Load(),
+		"CacheHits":    stats.CacheHits.Load(),
+		"CacheMisses":  stats.CacheMisses.Load(),
+	}
+	want := map[string]int64{
+		"PathAttempts": 2 * (4 + 2), // Four tag paths plus two projected sub-paths, per merge
+		"MethodCalls":  2,
+		"CacheHits":    1,
+		"CacheMisses":  1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithProfiling() stats = %v, want %v", got, want)
+	}
+}
+
+func TestSurfacePreloadTypes(t *testing.T) {
+	type Record struct {
+		URL string `smap:"EV.AISvcURL"`
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url"}}
+
+	var stats smap.Stats
+	m := smap.NewMerger(smap.WithProfiling(&stats), smap.WithCache(smap.NewCache()))
+	m.PreloadTypes((*Record)(nil), Config{}, "ignored", nil)
+	if misses := stats.CacheMisses.Load(); misses != 2 {
+		t.Errorf("PreloadTypes() cache misses = %d, want 2", misses)
+	}
+
+	var dst Record
+	if err := m.Merge(&dst, src); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if err := m.Merge(&Config{}, src); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if hits, misses := stats.CacheHits.Load(), stats.CacheMisses.Load(); hits != 2 || misses != 2 {
+		t.Errorf("Merge() cache hits, misses = %d, %d, want 2, 2", hits, misses)
+	}
+	if dst.URL != "env-url" {
+		t.Errorf("Merge() dst = %+v, want URL env-url", dst)
+	}
+
+	var uncached smap.Stats
+	smap.NewMerger(smap.WithProfiling(&uncached)).PreloadTypes(Record{})
+	if misses := uncached.CacheMisses.Load(); misses != 0 {
+		t.Errorf("PreloadTypes() without cache misses = %d, want 0", misses)
+	}
+}
+
+func TestSurfacePreloadTypesDefaultCache(t *testing.T) {
+	type Record struct {
+		URL string `smap:"EV.AISvcURL"`
+	}
+	smap.DefaultCache().Reset()
+	t.Cleanup(smap.DefaultCache().Reset)
+
+	smap.PreloadTypes(&Record{})
+
+	var stats smap.Stats
+	m := smap.NewMerger(smap.WithProfiling(&stats), smap.WithCache(smap.DefaultCache()))
+	var dst Record
+	if err := m.Merge(&dst, Sources{EV: &EnvVars{AISvcURL: "env-url"}}); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if hits, misses := stats.CacheHits.Load(), stats.CacheMisses.Load(); hits != 1 || misses != 0 {
+		t.Errorf("Merge() cache hits, misses = %d, %d, want 1, 0", hits, misses)
+	}
+	if dst.URL != "env-url" {
+		t.Errorf("Merge() dst = %+v, want URL env-url", dst)
+	}
+}
+
+func TestSurfacePositionalRow(t *testing.T) {
+	type Record struct {
+		Row []interface{}
+	}
+	type Account struct {
+		ID      int           `smap:"Row.0"`
+		Name    string        `smap:"Row.1"`
+		Active  bool          `smap:"Row.2"`
+		Balance float32       `smap:"Row.3"`
+		Visits  int64         `smap:"Row.4"`
+		Timeout time.Duration `smap:"Row.5"`
+		Email   *string       `smap:"Row.6"`
+		Shard   uint8         `smap:"Row.7"`
+	}
+
+	tests := []struct {
+		name    string
+		row     []interface{}
+		want    Account
+		wantErr error
+	}{
+		{
+			name: "hydrated",
+			row:  []interface{}{"42", "alice", "yes", 12.5, 7, "2s", nil, int64(3)},
+			want: Account{ID: 42, Name: "alice", Active: true, Balance: 12.5, Visits: 7, Timeout: 2 * time.Second, Shard: 3},
+		},
+		{
+			name: "whole_float_into_int",
+			row:  []interface{}{float64(42)},
+			want: Account{ID: 42},
+		},
+		{
+			name:    "fractional_float_into_int",
+			row:     []interface{}{3.5},
+			wantErr: strconv.ErrSyntax,
+		},
+		{
+			name:    "overflow",
+			row:     []interface{}{1, "bob", "no", 0.0, 0, "0s", nil, 300},
+			wantErr: smap.ErrValueOverflow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Account
+			err := smap.Merge(&got, Record{Row: tt.row}, smap.WithDefaultHydrate())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() dst = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceGatherPickPromoted(t *testing.T) {
+	type Inner struct {
+		X string
+	}
+	type Labels struct {
+		*Inner
+		Y string
+	}
+	type Source struct {
+		Labels Labels
+	}
+	type Record struct {
+		Picked map[string]string `smap:"Labels.{X,Y}"`
+	}
+
+	var got Record
+	if err := smap.Merge(&got, Source{Labels: Labels{Y: "y"}}); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if want := map[string]string{"Y": "y"}; !reflect.DeepEqual(got.Picked, want) {
+		t.Errorf("Merge() Picked = %v, want %v", got.Picked, want)
+	}
+
+	got = Record{}
+	if err := smap.Merge(&got, Source{Labels: Labels{Inner: &Inner{X: "x"}, Y: "y"}}); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if want := map[string]string{"X": "x", "Y": "y"}; !reflect.DeepEqual(got.Picked, want) {
+		t.Errorf("Merge() Picked = %v, want %v", got.Picked, want)
+	}
+}
+
+func TestSurfaceSliceKeywords(t *testing.T) {
+	type Record struct {
+		First    string `smap:"EV.Users.first"`
+		Last     string `smap:"EV.Users.last"`
+		Index    string `smap:"EV.Users.1"`
+		LastHost string `smap:"EV.Servers.last.Host|FV.Users.first"`
+	}
+
+	tests := []struct {
+		name string
+		ev   *EnvVars
+		fv   *FileVals
+		want Record
+	}{
+		{"empty", &EnvVars{Users: []string{}}, nil, Record{First: "-", Last: "-", Index: "-", LastHost: "-"}},
+		{
+			"single",
+			&EnvVars{Users: []string{"a"}},
+			&FileVals{Users: []string{"fv"}},
+			Record{First: "a", Last: "a", Index: "-", LastHost: "fv"},
+		},
+		{
+			"multi",
+			&EnvVars{Users: []string{"a", "b", "c"}, Servers: []Server{{Host: "x"}, {Host: "y"}}},
+			nil,
+			Record{First: "a", Last: "c", Index: "b", LastHost: "y"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := Record{First: "-", Last: "-", Index: "-", LastHost: "-"}
+			if err := smap.Merge(&dst, Sources{EV: tt.ev, FV: tt.fv}); err != nil {
+				t.Fatalf("Merge() error = %v, want nil", err)
+			}
+			if dst != tt.want {
+				t.Errorf("Merge() dst = %+v, want %+v", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceHydrateErrorWrap(t *testing.T) {
+	type Record struct {
+		Port int `smap:"EV.AISvcKey,hydrate"`
+	}
+	long := strings.Repeat("x", 70)
+
+	tests := []struct {
+		name    string
+		key     string
+		opts    []smap.Option
+		want    string
+		notWant string
+	}{
+		{"unwrapped", "secret", nil, "", `hydrate "secret"`},
+		{"wrapped", "secret", []smap.Option{smap.WithHydrateErrorWrap()}, `hydrate "secret"`, ""},
+		{"truncated", long, []smap.Option{smap.WithHydrateErrorWrap()}, `hydrate "` + long[:64] + `..."`, long[:65]},
+		{"redacted", "secret", []smap.Option{smap.WithHydrateErrorWrap(), smap.WithRedactHydrateInput()}, "hydrate [redacted]", "secret"},
+		{"redacted_unwrapped", "secret", []smap.Option{smap.WithRedactHydrateInput()}, "hydrate [redacted] failed", "secret"},
+		{"redacted_escaped", `hun"ter2`, []smap.Option{smap.WithHydrateErrorWrap(), smap.WithRedactHydrateInput()}, "hydrate [redacted] failed", "ter2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst Record
+			err := smap.Merge(&dst, Sources{EV: &EnvVars{AISvcKey: tt.key}}, tt.opts...)
+			var fieldErr *smap.MergeFieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Merge() error = %v, want *MergeFieldError", err)
+			}
+			if !errors.Is(err, strconv.ErrSyntax) {
+				t.Errorf("Merge() error = %v, want wrapped strconv.ErrSyntax", err)
+			}
+			msg := err.Error()
+			if !strings.Contains(msg, "dst type: int") {
+				t.Errorf("Merge() error = %q, want target type", msg)
+			}
+			if tt.want != "" && !strings.Contains(msg, tt.want) {
+				t.Errorf("Merge() error = %q, want it to contain %q", msg, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(msg, tt.notWant) {
+				t.Errorf("Merge() error = %q, want it to omit %q", msg, tt.notWant)
+			}
+		})
+	}
+
+	t.Run("apply_redacted", func(t *testing.T) {
+		var dst Record
+		err := smap.Apply(&dst, map[string]string{"Port": "hunter2"}, smap.WithRedactHydrateInput())
+		if !errors.Is(err, strconv.ErrSyntax) {
+			t.Fatalf("Apply() error = %v, want wrapped strconv.ErrSyntax", err)
+		}
+		if msg := err.Error(); strings.Contains(msg, "hunter2") {
+			t.Errorf("Apply() error = %q, want it to omit the input", msg)
+		}
+	})
+}
+
+func TestSurfaceRawTag(t *testing.T) {
+	type Record struct {
+		URL   string `smap:"EV.AISvcURL|FV.Service.URL"`
+		NoTag string
+		Key   string `smap:"EV..AISvcKey"`
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url"}}
+	want := []string{"URL=EV.AISvcURL|FV.Service.URL", "Key=EV..AISvcKey"}
+
+	cache := smap.NewCache()
+	for i := 0; i < 2; i++ {
+		var got []string
+		hook := func(field, rawTag string) {
+			got = append(got, field+"="+rawTag)
+		}
+		err := smap.Merge(&Record{}, src, smap.WithRawTag(hook), smap.WithCache(cache))
+		if !errors.Is(err, smap.ErrTagInvalid) {
+			t.Fatalf("Merge() (run %d) error = %v, want %v", i, err, smap.ErrTagInvalid)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WithRawTag() (run %d) calls = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSurfaceDefaultField(t *testing.T) {
+	type Record struct {
+		Name        string `smap:"EV.AISvcKey"`
+		DisplayName string `smap:"EV.URL,defaultfield=Name"`
+		Alias       string `smap:"EV.AISvcURL,defaultfield=Name"`
+	}
+	type Untagged struct {
+		DisplayName string `smap:"EV.URL,defaultfield=Name"`
+		Name        string
+	}
+	type Later struct {
+		DisplayName string `smap:"EV.URL,defaultfield=Name"`
+		Name        string `smap:"EV.AISvcKey"`
+	}
+	type Chained struct {
+		Label       string `smap:"EV.URL,defaultfield=DisplayName"`
+		DisplayName string `smap:"EV.URL,defaultfield=Name"`
+		Name        string `smap:"EV.AISvcKey"`
+	}
+	type Self struct {
+		Name string `smap:"EV.URL,defaultfield=Name"`
+	}
+	type Mutual struct {
+		Name        string `smap:"EV.URL,defaultfield=DisplayName"`
+		DisplayName string `smap:"EV.URL,defaultfield=Name"`
+	}
+	type Missing struct {
+		DisplayName string `smap:"EV.URL,defaultfield=Nope"`
+	}
+	type Incompatible struct {
+		Count       int    `smap:"EV.Count"`
+		DisplayName string `smap:"EV.URL,defaultfield=Count"`
+	}
+	src := Sources{EV: &EnvVars{AISvcKey: "env-key", AISvcURL: "env-url", Count: 3}}
+
+	tests := []struct {
+		name    string
+		dst     interface{}
+		opts    []smap.Option
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "copies_merged_sibling",
+			dst:  &Record{},
+			want: Record{Name: "env-key", DisplayName: "env-key", Alias: "env-url"},
+		},
+		{
+			name: "copies_merged_sibling_concurrently",
+			dst:  &Record{},
+			opts: []smap.Option{smap.WithConcurrentFields(3)},
+			want: Record{Name: "env-key", DisplayName: "env-key", Alias: "env-url"},
+		},
+		{
+			name: "zero_sibling_leaves_field",
+			dst:  &Untagged{DisplayName: "keep"},
+			want: Untagged{DisplayName: "keep"},
+		},
+		{
+			name: "untagged_sibling",
+			dst:  &Untagged{Name: "preset"},
+			want: Untagged{Name: "preset", DisplayName: "preset"},
+		},
+		{
+			name: "sibling_declared_later",
+			dst:  &Later{},
+			want: Later{Name: "env-key", DisplayName: "env-key"},
+		},
+		{
+			name: "sibling_ordered_after",
+			dst:  &Later{},
+			opts: []smap.Option{smap.WithFieldOrder("DisplayName")},
+			want: Later{Name: "env-key", DisplayName: "env-key"},
+		},
+		{
+			name: "sibling_chain_concurrently",
+			dst:  &Chained{},
+			opts: []smap.Option{smap.WithConcurrentFields(3)},
+			want: Chained{Label: "env-key", DisplayName: "env-key", Name: "env-key"},
+		},
+		{
+			name:    "self_reference",
+			dst:     &Self{},
+			want:    Self{},
+			wantErr: smap.ErrFieldCycle,
+		},
+		{
+			name:    "mutual_reference",
+			dst:     &Mutual{},
+			want:    Mutual{},
+			wantErr: smap.ErrFieldCycle,
+		},
+		{
+			name:    "sibling_missing",
+			dst:     &Missing{},
+			want:    Missing{},
+			wantErr: smap.ErrDefaultFieldInvalid,
+		},
+		{
+			name:    "sibling_incompatible",
+			dst:     &Incompatible{},
+			want:    Incompatible{Count: 3},
+			wantErr: smap.ErrFieldTypesIncompatible,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := smap.Merge(tt.dst, src, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := reflect.ValueOf(tt.dst).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() dst = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceFieldAlias(t *testing.T) {
+	type Legacy struct {
+		URL     string `smap:"EV.AISvcURL"`
+		Display string `smap:"EV.URL,defaultfield=Url"`
+		Copy    string `smap:"EV.URL,defaultfield=Kee"`
+		Key     string `smap:"EV.AISvcKey"`
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key"}}
+	alias := smap.WithFieldAlias(map[string]string{"Url": "URL", "Kee": "Key"})
+
+	var got Legacy
+	if err := smap.Merge(&got, src, alias, smap.WithFieldOrder("Kee")); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+	if want := (Legacy{URL: "env-url", Display: "env-url", Copy: "env-key", Key: "env-key"}); got != want {
+		t.Errorf("Merge() dst = %+v, want %+v", got, want)
+	}
+
+	got = Legacy{}
+	if err := smap.Merge(&got, src, alias); err != nil || got.Copy != "env-key" {
+		t.Errorf("Merge() without order = %+v, %v, want Copy env-key, nil", got, err)
+	}
+	if err := smap.Merge(&Legacy{}, src, smap.WithFieldOrder("Key")); !errors.Is(err, smap.ErrDefaultFieldInvalid) {
+		t.Errorf("Merge() without alias error = %v, want %v", err, smap.ErrDefaultFieldInvalid)
+	}
+
+	v, ok, err := smap.ResolvedValue(&Legacy{}, "Url", src, alias)
+	if err != nil || !ok || v != "env-url" {
+		t.Errorf("ResolvedValue() = %v, %v, %v, want env-url, true, nil", v, ok, err)
+	}
+
+	var applied Legacy
+	if err := smap.Apply(&applied, map[string]string{"Url": "override"}, alias); err != nil {
+		t.Fatalf("Apply() error = %v, want nil", err)
+	}
+	if applied.URL != "override" {
+		t.Errorf("Apply() URL = %q, want %q", applied.URL, "override")
+	}
+}
+
+func TestSurfaceAssignHook(t *testing.T) {
+	type Record struct {
+		URL   string `smap:"EV.AISvcURL"`
+		Key   string `smap:"EV.AISvcKey"`
+		Count int    `smap:"EV.Value,hydrate"`
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key", Value: "42"}}
+
+	type change struct {
+		field    string
+		old, new interface{}
+	}
+	var changes []change
+	hook := func(field string, old, new reflect.Value) bool {
+		changes = append(changes, change{field, old.Interface(), new.Interface()})
+		return field != "Key" // Key is change-controlled
+	}
+
+	dst := Record{Key: "approved-key"}
+	unresolved, err := smap.MergeWithResult(&dst, src, smap.WithAssignHook(hook))
+	if err != nil {
+		t.Fatalf("MergeWithResult() error = %v, want nil", err)
+	}
+	if want := (Record{URL: "env-url", Key: "approved-key", Count: 42}); dst != want {
+		t.Errorf("MergeWithResult() dst = %+v, want %+v", dst, want)
+	}
+	if want := []string{"Key"}; !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("MergeWithResult() unresolved = %v, want %v", unresolved, want)
+	}
+	wantChanges := []change{
+		{"URL", "", "env-url"},
+		{"Key", "approved-key", "env-key"},
+		{"Count", 0, 42},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("WithAssignHook() calls = %+v, want %+v", changes, wantChanges)
+	}
+}
+
+func TestSurfaceFieldOrder(t *testing.T) {
+	type Ordered struct {
+		URL   string `smap:"EV.AISvcURL"`
+		Key   string `smap:"EV.AISvcKey"`
+		Value string `smap:"EV.Value"`
+		Count int    `smap:"EV.Count"`
+	}
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url", AISvcKey: "env-key", Value: "v", Count: 1}}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []string
+	}{
+		{name: "declaration", order: nil, want: []string{"URL", "Key", "Value", "Count"}},
+		{name: "named_first", order: []string{"Count", "Key"}, want: []string{"Count", "Key", "URL", "Value"}},
+		{name: "unknown_ignored", order: []string{"Missing", "Value"}, want: []string{"Value", "URL", "Key", "Count"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			record := func(field string, _ reflect.Value) error {
+				got = append(got, field)
+				return nil
+			}
+			var dst Ordered
+			err := smap.Merge(&dst, src, smap.WithValueValidator(record), smap.WithFieldOrder(tt.order...))
+			if err != nil {
+				t.Fatalf("Merge() error = %v, want nil", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() field order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceConcurrentFieldsError(t *testing.T) {
+	type Record struct {
+		Enabled bool   `smap:"EV.Flag,intbool=strict"`
+		URL     string `smap:"EV.AISvcURL"`
+		Key     string `smap:"EV.AISvcKey"`
+	}
+	src := Sources{EV: &EnvVars{Flag: 2, AISvcURL: "env-url", AISvcKey: "env-key"}}
+
+	tests := []struct {
+		name string
+		n    int
+		want Record
+	}{
+		{name: "sequential_stops", n: 1, want: Record{}},
+		{name: "concurrent_merges_all", n: 3, want: Record{URL: "env-url", Key: "env-key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst Record
+			err := smap.Merge(&dst, src, smap.WithConcurrentFields(tt.n))
+			if !errors.Is(err, smap.ErrIntBoolInvalid) {
+				t.Fatalf("Merge() error = %v, want %v", err, smap.ErrIntBoolInvalid)
+			}
+			if dst != tt.want {
+				t.Errorf("Merge() dst = %+v, want %+v", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurfaceLogger(t *testing.T) {
+	type Logged struct {
+		URL string `smap:"EV.AISvcURL|FV.Service.URL"`
+		Key string `smap:"EV.Missing,onerror=skip"`
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	src := Sources{EV: &EnvVars{AISvcURL: "env-url"}}
+
+	var dst Logged
+	if err := smap.Merge(&dst, src, smap.WithLogger(logger)); err != nil {
+		t.Fatalf("Merge() error = %v, want nil", err)
+	}
+
+	wants := []string{
+		`msg="smap: path" tag=EV.AISvcURL|FV.Service.URL path=EV.AISvcURL outcome=resolved`,
+		`msg="smap: path" tag=EV.AISvcURL|FV.Service.URL path=FV.Service.URL outcome=unset`,
+		`msg="smap: path" tag=EV.AISvcURL|FV.Service.URL path=EV.AISvcURL outcome=won`,
+		`msg="smap: field" field=URL tag=EV.AISvcURL|FV.Service.URL outcome=merged`,
+		`msg="smap: path" tag="EV.Missing,onerror=skip" path=EV.Missing outcome=failed`,
+		`msg="smap: field" field=Key tag="EV.Missing,onerror=skip" outcome="skipped error"`,
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(wants) {
+		t.Fatalf("Merge() logged %d records, want %d:\n%s", len(lines), len(wants), buf.String())
+	}
+	for i, want := range wants {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("record %d = %q, want it to contain %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestSurfaceApply(t *testing.T) {
+	type Applied struct {
+		Host    string
+		Port    int
+		Timeout *time.Duration
+		Base    url.URL
+		Tagged  string `smap:"EV.Value"`
+		hidden  string
+	}
+
+	t.Run("all_valid", func(t *testing.T) {
+		var got Applied
+		err := smap.Apply(&got, map[string]string{
+			"Host":    "localhost",
+			"Port":    "8080",
+			"Timeout": "5s",
+			"Base":    "http://example.com/api",
+			"Tagged":  "untagged use",
+		})
+		if err != nil {
+			t.Fatalf("Apply() error = %v, want nil", err)
+		}
+		timeout := 5 * time.Second
+		want := Applied{
+			Host:    "localhost",
+			Port:    8080,
+			Timeout: &timeout,
+			Base:    url.URL{Scheme: "http", Host: "example.com", Path: "/api"},
+			Tagged:  "untagged use",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Apply() got = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("errors_collected", func(t *testing.T) {
+		got := Applied{Port: 1}
+		err := smap.Apply(&got, map[string]string{
+			"Host":    "localhost",
+			"Port":    "not-a-number",
+			"hidden":  "x",
+			"Missing": "x",
+		})
+		if !errors.Is(err, smap.ErrFieldNotFound) {
+			t.Errorf("Apply() error = %v, want %v", err, smap.ErrFieldNotFound)
+		}
+		var names []string
+		for _, fieldErr := range smap.FieldErrors(err) {
+			if fieldErr.TagValue != "" {
+				t.Errorf("Apply() error TagValue = %q, want empty", fieldErr.TagValue)
+			}
+			names = append(names, fieldErr.FieldName)
+		}
+		if msg := err.Error(); !strings.Contains(msg, "field: Missing,") {
+			t.Errorf("Apply() error = %q, want it to name the Missing field", msg)
+		}
+		if want := []string{"Missing", "Port", "hidden"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("Apply() failed keys = %v, want %v", names, want)
+		}
+		if want := (Applied{Host: "localhost", Port: 1}); !reflect.DeepEqual(got, want) {
+			t.Errorf("Apply() got = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("invalid_dst", func(t *testing.T) {
+		if err := smap.Apply(Applied{}, nil); !errors.Is(err, smap.ErrDstInvalid) {
+			t.Errorf("Apply() error = %v, want %v", err, smap.ErrDstInvalid)
+		}
+	})
+}
+
+func TestSurfaceFieldErrors(t *testing.T) {
+	first := smap.NewMergeFieldError(smap.ErrTagPathNotFound, "EV.Missing", "string", "")
+	second := smap.NewMergeFieldError(smap.ErrFieldTypesIncompatible, "EV.Count", "string", "int")
+
+	tests := []struct {
+		name string
+		err  error
+		want []*smap.MergeFieldError
+	}{
+		{name: "nil", err: nil, want: nil},
+		{name: "unrelated", err: errors.New("boom"), want: nil},
+		{name: "single", err: first, want: []*smap.MergeFieldError{first}},
+		{name: "wrapped", err: fmt.Errorf("merge: %w", first), want: []*smap.MergeFieldError{first}},
+		{
+			name: "joined",
+			err:  joinedErrors{first, errors.New("boom"), fmt.Errorf("merge: %w", second)},
+			want: []*smap.MergeFieldError{first, second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := smap.FieldErrors(tt.err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FieldErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// joinedErrors mirrors the errors.Join result shape.
+type joinedErrors []error
+
+func (e joinedErrors) Error() string { return "joined" }
+
+func (e joinedErrors) Unwrap() []error { return e }
+
+// Helper to create *string
+func strPtr(s string) *string {
+	return &s
+}
+
+// Helper to create *int
+func intPtr(n int) *int {
+	return &n
+}
+
+// Helper to create *ID
+func idPtr(id ID) *ID {
+	return &id
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
 }
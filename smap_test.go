@@ -100,11 +100,47 @@ func TestUnitNewSTag(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name:   "pick segment with options",
+			rawTag: "EV.{AISvcURL,AISvcKey}|FV.*,skipzero",
+			want: &sTag{
+				pathsParts: tagPathsParts{{"EV", "{AISvcURL,AISvcKey}"}, {"FV", "*"}},
+				opts:       []string{"skipzero"},
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "pick segment attached to segment",
+			rawTag: "EV{AISvcURL,AISvcKey},skipzero",
+			want: &sTag{
+				pathsParts: tagPathsParts{{"EV", "{AISvcURL,AISvcKey}"}},
+				opts:       []string{"skipzero"},
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "path with valued option",
+			rawTag: "EV.Flag,intbool=strict",
+			want: &sTag{
+				pathsParts: tagPathsParts{{"EV", "Flag"}},
+				opts:       []string{"intbool=strict"},
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "quoted segment with separators",
+			rawTag: `EV.Data."a,b"|EV.Data."a.b|c",skipzero`,
+			want: &sTag{
+				pathsParts: tagPathsParts{{"EV", "Data", "a,b"}, {"EV", "Data", "a.b|c"}},
+				opts:       []string{"skipzero"},
+			},
+			wantErr: nil,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newSTag(tt.rawTag)
+			got, err := newSTag(tt.rawTag, defaultTagSplitter)
 			if tt.wantErr != nil {
 				if !errors.Is(err, tt.wantErr) {
 					t.Errorf("newSTag() error = %v, want %v", err, tt.wantErr)
@@ -132,6 +168,109 @@ func TestUnitNewSTag(t *testing.T) {
 	}
 }
 
+func TestUnitSTagStringQuoted(t *testing.T) {
+	for _, rawTag := range []string{
+		`EV.Data."a,b"|EV.Data."a.b|c",skipzero`,
+		`EV.{AISvcURL,AISvcKey}`,
+	} {
+		tag, err := newSTag(rawTag, TagSplitter{})
+		if err != nil {
+			t.Fatalf("newSTag(%q) error = %v, want nil", rawTag, err)
+		}
+		if got := tag.String(); got != rawTag {
+			t.Errorf("newSTag(%q).String() = %q, want the tag unchanged", rawTag, got)
+		}
+	}
+}
+
+func TestUnitNewSTagErrorDetail(t *testing.T) {
+	tests := []struct {
+		name       string
+		rawTag     string
+		wantDetail string
+	}{
+		{name: "double dot", rawTag: "Foo..Bar", wantDetail: "empty segment in path"},
+		{name: "leading dot", rawTag: ".Foo.Bar", wantDetail: "leading dot in path"},
+		{name: "trailing dot", rawTag: "Foo.Bar.", wantDetail: "trailing dot in path"},
+		{name: "empty option", rawTag: "Foo.Bar,,hydrate", wantDetail: "empty option"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSTag(tt.rawTag, defaultTagSplitter)
+			if !errors.Is(err, ErrTagInvalid) {
+				t.Fatalf("newSTag() error = %v, want %v", err, ErrTagInvalid)
+			}
+			var tagErr *TagError
+			if !errors.As(err, &tagErr) {
+				t.Fatalf("newSTag() error = %T, want *TagError", err)
+			}
+			if tagErr.RawTag != tt.rawTag || tagErr.Detail != tt.wantDetail {
+				t.Errorf("newSTag() error = (%q, %q), want (%q, %q)", tagErr.RawTag, tagErr.Detail, tt.rawTag, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestUnitNewSTagCustomSplitter(t *testing.T) {
+	sp := TagSplitter{Paths: " ", Options: ";", Segments: "/"}
+	got, err := newSTag("EV/AISvcURL FV/Service/URL;skipzero;hydrate", sp)
+	if err != nil {
+		t.Fatalf("newSTag() error = %v, want nil", err)
+	}
+	wantPaths := tagPathsParts{{"EV", "AISvcURL"}, {"FV", "Service", "URL"}}
+	if !reflect.DeepEqual(got.pathsParts, wantPaths) {
+		t.Errorf("newSTag().pathsParts = %v, want %v", got.pathsParts, wantPaths)
+	}
+	wantOpts := []string{"skipzero", "hydrate"}
+	if !reflect.DeepEqual(got.opts, wantOpts) {
+		t.Errorf("newSTag().opts = %v, want %v", got.opts, wantOpts)
+	}
+	wantStr := "EV/AISvcURL FV/Service/URL;skipzero;hydrate"
+	if got.String() != wantStr {
+		t.Errorf("newSTag().String() = %q, want %q", got.String(), wantStr)
+	}
+
+	pick, err := newSTag("EV/{URL;Key};skipzero", sp)
+	if err != nil {
+		t.Fatalf("newSTag() error = %v, want nil", err)
+	}
+	if names := pick.pathsParts[0].GatherNames(sp.Options); !reflect.DeepEqual(names, []string{"URL", "Key"}) {
+		t.Errorf("GatherNames() = %q, want [URL Key]", names)
+	}
+
+	_, err = newSTag("/EV/URL", sp)
+	var tagErr *TagError
+	if !errors.As(err, &tagErr) || tagErr.Detail != `leading "/" in path` {
+		t.Errorf("newSTag() error = %v, want leading \"/\" detail", err)
+	}
+}
+
+func TestUnitNewSTagSplitterConflict(t *testing.T) {
+	tests := []struct {
+		name       string
+		sp         TagSplitter
+		wantDetail string
+	}{
+		{name: "equal", sp: TagSplitter{Paths: "."}, wantDetail: `paths separator "." overlaps segments separator "."`},
+		{name: "containing", sp: TagSplitter{Options: "::", Segments: ":"}, wantDetail: `options separator "::" overlaps segments separator ":"`},
+		{name: "brace", sp: TagSplitter{Paths: "}"}, wantDetail: `paths separator "}" contains a quote or brace`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSTag("EV.URL", tt.sp)
+			if !errors.Is(err, ErrTagSplitterInvalid) {
+				t.Fatalf("newSTag() error = %v, want %v", err, ErrTagSplitterInvalid)
+			}
+			var tagErr *TagError
+			if !errors.As(err, &tagErr) || tagErr.Detail != tt.wantDetail {
+				t.Errorf("newSTag() error = %v, want detail %q", err, tt.wantDetail)
+			}
+		})
+	}
+}
+
 // Define MethodStruct with methods for testing
 type MethodStruct struct {
 	Value string
@@ -145,6 +284,124 @@ func (ms *MethodStruct) GetValueErr() (string, error) {
 	return "", errors.New("method error")
 }
 
+func (ms *MethodStruct) GetValueNilErr() (string, error) {
+	return "nil error value", nil
+}
+
+func (ms *MethodStruct) GetName() string {
+	return "getter name"
+}
+
+func (ms *MethodStruct) Check() error {
+	if ms.Value == "" {
+		return errors.New("check error")
+	}
+	return nil
+}
+
+// ShadowStruct declares a method sharing the name of a promoted field
+type ShadowStruct struct {
+	MethodStruct
+}
+
+func (ss *ShadowStruct) Value() string {
+	return "method value"
+}
+
+// Namer is embedded as an interface to test promoted method lookup
+type Namer interface {
+	Name() string
+}
+
+type fixedName string
+
+func (n fixedName) Name() string {
+	return string(n)
+}
+
+type EmbeddedNamer struct {
+	Namer
+}
+
+type EmbeddedNamerPtr struct {
+	*EmbeddedNamer
+}
+
+// ShadowingNamer declares the method its embedded interface also has
+type ShadowingNamer struct {
+	Namer
+}
+
+func (ShadowingNamer) Name() string {
+	return "outer"
+}
+
+// ShadowingNamerPtr declares the method on its pointer receiver
+type ShadowingNamerPtr struct {
+	*EmbeddedNamer
+}
+
+func (*ShadowingNamerPtr) Name() string {
+	return "outer pointer"
+}
+
+func TestUnitDeclaresMethod(t *testing.T) {
+	type Shallower struct {
+		Namer
+		*EmbeddedNamer
+	}
+	type OtherNamer interface {
+		Name() string
+	}
+	type Ambiguous struct {
+		Namer
+		OtherNamer
+	}
+
+	tests := []struct {
+		name string
+		typ  reflect.Type
+		want bool
+	}{
+		{name: "declared", typ: reflect.TypeOf(MethodStruct{}), want: true},
+		{name: "promoted from interface", typ: reflect.TypeOf(EmbeddedNamer{}), want: false},
+		{name: "promoted from pointer", typ: reflect.TypeOf(EmbeddedNamerPtr{}), want: false},
+		{name: "shadowing value method", typ: reflect.TypeOf(ShadowingNamer{}), want: true},
+		{name: "shadowing pointer method", typ: reflect.TypeOf(ShadowingNamerPtr{}), want: true},
+		{name: "promoted from shallower embed", typ: reflect.TypeOf(Shallower{}), want: false},
+		{name: "ambiguous embeds", typ: reflect.TypeOf(Ambiguous{}), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := "Name"
+			if tt.typ == reflect.TypeOf(MethodStruct{}) {
+				method = "GetName"
+			}
+			if got := declaresMethod(tt.typ, method); got != tt.want {
+				t.Errorf("declaresMethod(%v, %q) = %v, want %v", tt.typ, method, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUnitPromotedWrapper pins the toolchain behavior isPromotedWrapper relies
+// on: promoted methods and pointer wrappers are compiler generated.
+func TestUnitPromotedWrapper(t *testing.T) {
+	promoted, _ := reflect.TypeOf(EmbeddedNamer{}).MethodByName("Name")
+	if !isPromotedWrapper(promoted.Func) {
+		t.Errorf("isPromotedWrapper(EmbeddedNamer.Name) = false, want true")
+	}
+	wrapper, _ := reflect.TypeOf(&ShadowingNamer{}).MethodByName("Name")
+	if !isPromotedWrapper(wrapper.Func) {
+		t.Errorf("isPromotedWrapper((*ShadowingNamer).Name) = false, want true")
+	}
+	declared, _ := reflect.TypeOf(ShadowingNamer{}).MethodByName("Name")
+	if isPromotedWrapper(declared.Func) {
+		t.Errorf("isPromotedWrapper(ShadowingNamer.Name) = true, want false")
+	}
+}
+
 func TestUnitLookUpField(t *testing.T) {
 	type Inner struct {
 		url string // unexported
@@ -160,13 +417,26 @@ func TestUnitLookUpField(t *testing.T) {
 		BoolMap  map[bool]string // Added for unsupported key type
 	}
 	type MapOuter struct {
-		Data map[string]string
+		Data     map[string]string
+		Inners   map[string]Inner
+		InnerPtr map[string]*Inner
+	}
+	type embedded struct {
+		URL string
+	}
+	type Embedding struct {
+		embedded
+	}
+	type EmbeddingPtr struct {
+		*embedded
 	}
 
 	tests := []struct {
 		name      string
 		src       interface{}
 		pathParts tagPathParts
+		opts      []Option
+		runes     bool
 		want      interface{}
 		wantErr   error
 	}{
@@ -255,6 +525,73 @@ func TestUnitLookUpField(t *testing.T) {
 			want:      nil,
 			wantErr:   errors.New("method error"),
 		},
+		{
+			name:      "method value and nil error",
+			src:       &MethodStruct{Value: "struct value"},
+			pathParts: tagPathParts{"GetValueNilErr"},
+			want:      "nil error value",
+			wantErr:   nil,
+		},
+		{
+			name:      "method error only nil",
+			src:       &MethodStruct{Value: "struct value"},
+			pathParts: tagPathParts{"Check"},
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
+		{
+			name:      "method error only non-nil",
+			src:       &MethodStruct{},
+			pathParts: tagPathParts{"Check"},
+			want:      nil,
+			wantErr:   errors.New("check error"),
+		},
+		{
+			name:      "getter without fallback",
+			src:       &MethodStruct{Value: "struct value"},
+			pathParts: tagPathParts{"Name"},
+			want:      nil,
+			wantErr:   ErrTagPathNotFound,
+		},
+		{
+			name:      "getter fallback",
+			src:       &MethodStruct{Value: "struct value"},
+			pathParts: tagPathParts{"Name"},
+			opts:      []Option{WithGetterFallback()},
+			want:      "getter name",
+			wantErr:   nil,
+		},
+		{
+			name:      "getter fallback prefers field",
+			src:       &MethodStruct{Value: "struct value"},
+			pathParts: tagPathParts{"Value"},
+			opts:      []Option{WithGetterFallback()},
+			want:      "struct value",
+			wantErr:   nil,
+		},
+		{
+			name:      "promoted field before method",
+			src:       &ShadowStruct{MethodStruct{Value: "struct value"}},
+			pathParts: tagPathParts{"Value"},
+			want:      "struct value",
+			wantErr:   nil,
+		},
+		{
+			name:      "prefer method",
+			src:       &ShadowStruct{MethodStruct{Value: "struct value"}},
+			pathParts: tagPathParts{"Value"},
+			opts:      []Option{WithPreferMethod()},
+			want:      "method value",
+			wantErr:   nil,
+		},
+		{
+			name:      "prefer method falls back to field",
+			src:       &MethodStruct{Value: "struct value"},
+			pathParts: tagPathParts{"Value"},
+			opts:      []Option{WithPreferMethod()},
+			want:      "struct value",
+			wantErr:   nil,
+		},
 		{
 			name:      "float map key",
 			src:       Outer{FloatMap: map[float64]int{1.5: 42}},
@@ -276,6 +613,134 @@ func TestUnitLookUpField(t *testing.T) {
 			want:      nil,
 			wantErr:   errKeepLooking,
 		},
+		{
+			name:      "promoted field of unexported embedded struct",
+			src:       Embedding{embedded{URL: "http://example.com"}},
+			pathParts: tagPathParts{"URL"},
+			want:      "http://example.com",
+			wantErr:   nil,
+		},
+		{
+			name:      "promoted field of unexported embedded pointer",
+			src:       EmbeddingPtr{&embedded{URL: "http://example.com"}},
+			pathParts: tagPathParts{"URL"},
+			want:      "http://example.com",
+			wantErr:   nil,
+		},
+		{
+			name:      "promoted field of nil unexported embedded pointer",
+			src:       EmbeddingPtr{},
+			pathParts: tagPathParts{"URL"},
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
+		{
+			name:      "string byte index",
+			src:       Outer{Inner: Inner{URL: "héllo"}},
+			pathParts: tagPathParts{"Inner", "URL", "1"},
+			want:      byte(0xc3),
+			wantErr:   nil,
+		},
+		{
+			name:      "string rune index",
+			src:       Outer{Inner: Inner{URL: "héllo"}},
+			pathParts: tagPathParts{"Inner", "URL", "1"},
+			runes:     true,
+			want:      'é',
+			wantErr:   nil,
+		},
+		{
+			name:      "string index out of range",
+			src:       Outer{Inner: Inner{URL: "héllo"}},
+			pathParts: tagPathParts{"Inner", "URL", "5"},
+			runes:     true,
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
+		{
+			name:      "string index not last",
+			src:       Outer{Inner: Inner{URL: "héllo"}},
+			pathParts: tagPathParts{"Inner", "URL", "0", "0"},
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
+		{
+			name:      "method promoted from embedded interface",
+			src:       EmbeddedNamer{Namer: fixedName("promoted")},
+			pathParts: tagPathParts{"Name"},
+			want:      "promoted",
+			wantErr:   nil,
+		},
+		{
+			name:      "method promoted from nil embedded interface",
+			src:       EmbeddedNamer{},
+			pathParts: tagPathParts{"Name"},
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
+		{
+			name:      "method promoted through embedded pointer and interface",
+			src:       &EmbeddedNamerPtr{&EmbeddedNamer{Namer: fixedName("deep")}},
+			pathParts: tagPathParts{"Name"},
+			want:      "deep",
+			wantErr:   nil,
+		},
+		{
+			name:      "method promoted from nil embedded pointer",
+			src:       &EmbeddedNamerPtr{},
+			pathParts: tagPathParts{"Name"},
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
+		{
+			name:      "declared method shadows nil embedded interface",
+			src:       ShadowingNamer{},
+			pathParts: tagPathParts{"Name"},
+			want:      "outer",
+			wantErr:   nil,
+		},
+		{
+			name:      "declared method shadows nil embedded interface through pointer",
+			src:       &ShadowingNamer{},
+			pathParts: tagPathParts{"Name"},
+			want:      "outer",
+			wantErr:   nil,
+		},
+		{
+			name:      "declared pointer method shadows nil embedded pointer",
+			src:       &ShadowingNamerPtr{},
+			pathParts: tagPathParts{"Name"},
+			want:      "outer pointer",
+			wantErr:   nil,
+		},
+		{
+			name:      "struct field through map value",
+			src:       MapOuter{Inners: map[string]Inner{"key": {URL: "http://map.example"}}},
+			pathParts: tagPathParts{"Inners", "key", "URL"},
+			want:      "http://map.example",
+			wantErr:   nil,
+		},
+		{
+			name:      "struct field through map pointer value",
+			src:       MapOuter{InnerPtr: map[string]*Inner{"key": {URL: "http://ptr.example"}}},
+			pathParts: tagPathParts{"InnerPtr", "key", "URL"},
+			want:      "http://ptr.example",
+			wantErr:   nil,
+		},
+		{
+			name:      "struct field through nil map pointer value",
+			src:       MapOuter{InnerPtr: map[string]*Inner{"key": nil}},
+			pathParts: tagPathParts{"InnerPtr", "key", "URL"},
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
+		{
+			name:      "navigation past string map value",
+			src:       MapOuter{Data: map[string]string{"key": "value"}},
+			pathParts: tagPathParts{"Data", "key", "URL"},
+			want:      nil,
+			wantErr:   errKeepLooking,
+		},
 		{
 			name:      "unsupported map key type",
 			src:       Outer{BoolMap: map[bool]string{true: "yes"}},
@@ -288,7 +753,7 @@ func TestUnitLookUpField(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			srcVal := reflect.ValueOf(tt.src)
-			got, err := lookUpField(srcVal, tt.pathParts)
+			got, err := lookUpField(newConfig(tt.opts...), srcVal, tt.pathParts, nil, tt.runes)
 			if tt.wantErr != nil {
 				if err == nil || err.Error() != tt.wantErr.Error() {
 					t.Errorf("lookUpField() error = %v, want %v", err, tt.wantErr)
@@ -319,3 +784,38 @@ func TestUnitLookUpField(t *testing.T) {
 		})
 	}
 }
+
+func TestUnitDependencyOrder(t *testing.T) {
+	type Chain struct {
+		Label string `smap:"EV.URL,defaultfield=Name"`
+		Other string `smap:"EV.Key"`
+		Name  string `smap:"EV.Name,defaultfield=Base"`
+		Base  string `smap:"EV.Base"`
+	}
+	type Cycle struct {
+		Lead string `smap:"EV.Lead,defaultfield=A"`
+		A    string `smap:"EV.A,defaultfield=B"`
+		B    string `smap:"EV.B,defaultfield=A"`
+	}
+	cfg := newConfig()
+
+	typ := reflect.TypeOf(Chain{})
+	ordered, err := dependencyOrder(cfg, typ, cfg.fieldPlans(typ))
+	if err != nil {
+		t.Fatalf("dependencyOrder() error = %v, want nil", err)
+	}
+	var names []string
+	for _, plan := range ordered {
+		names = append(names, plan.name)
+	}
+	if want := []string{"Base", "Name", "Label", "Other"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("dependencyOrder() = %v, want %v", names, want)
+	}
+
+	typ = reflect.TypeOf(Cycle{})
+	_, err = dependencyOrder(cfg, typ, cfg.fieldPlans(typ))
+	var tagErr *TagError
+	if !errors.Is(err, ErrFieldCycle) || !errors.As(err, &tagErr) || tagErr.Detail != "field cycle A -> B -> A" {
+		t.Errorf("dependencyOrder() error = %v, want %v with detail %q", err, ErrFieldCycle, "field cycle A -> B -> A")
+	}
+}
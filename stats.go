@@ -0,0 +1,16 @@
+package smap
+
+import (
+	"sync/atomic"
+)
+
+// Stats accumulates counters describing the work done by merges configured
+// with WithProfiling, to help tune large loads (e.g. to spot expensive source
+// methods or tags with many paths). Counters are updated atomically, so a
+// Stats may be shared by concurrent merges and read at any time.
+type Stats struct {
+	PathAttempts atomic.Int64 // Tag paths resolved, including projected sub-paths
+	MethodCalls  atomic.Int64 // Source methods called while resolving paths
+	CacheHits    atomic.Int64 // Field plans found in the Cache (see WithCache)
+	CacheMisses  atomic.Int64 // Field plans parsed and stored in the Cache
+}
@@ -1,12 +1,24 @@
 package smap
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 )
 
 // TagKey is the struct tag key used to define source paths.
 const TagKey = "smap"
 
+// gatherSegment is the final path segment that gathers all of a struct's
+// exported fields, or all of a map's entries, into a map destination. A final
+// pick segment (e.g. "{URL,Key}") gathers only the listed names.
+const gatherSegment = "*"
+
+// projectSegment is the path segment that maps the rest of the path over each
+// element of a slice or array, collecting the results into a slice
+// destination (e.g. "Servers.#.Host").
+const projectSegment = "#"
+
 // tagPathParts represents a single path segment in a smap tag.
 type tagPathParts []string
 
@@ -15,11 +27,73 @@ func (p tagPathParts) String() string {
 	return strings.Join(p, ".")
 }
 
+// Join joins the segments with the segment separator of sp, quoting segments
+// that contain a separator so that the result parses back to the same parts.
+func (p tagPathParts) Join(sp TagSplitter) string {
+	sp = sp.withDefaults()
+	segments := make([]string, len(p))
+	for i, segment := range p {
+		segments[i] = quoteSegment(segment, sp)
+	}
+	return strings.Join(segments, sp.Segments)
+}
+
+// splitPath splits a path written with the segment separator sep into its
+// unquoted segments, as tag parsing does.
+func splitPath(path, sep string) tagPathParts {
+	segments := splitOutsideBraces(path, sep, -1)
+	for i, segment := range segments {
+		segments[i] = unquoteSegment(segment)
+	}
+	return segments
+}
+
 // IsEmpty checks if the tagPathParts is empty.
 func (p tagPathParts) IsEmpty() bool {
 	return len(p) == 0
 }
 
+// IsGather checks if the tagPathParts ends with the wildcard segment or a pick
+// segment (e.g. "{URL,Key}").
+func (p tagPathParts) IsGather() bool {
+	if len(p) == 0 {
+		return false
+	}
+	last := p[len(p)-1]
+	return last == gatherSegment || isPickSegment(last)
+}
+
+// ProjectionIndex returns the index of the first projection segment, or -1
+// when the path does not project.
+func (p tagPathParts) ProjectionIndex() int {
+	for i, part := range p {
+		if part == projectSegment {
+			return i
+		}
+	}
+	return -1
+}
+
+// GatherNames returns the names listed in a final pick segment, separated by
+// sep (the option separator), or nil when the path gathers everything or does
+// not gather.
+func (p tagPathParts) GatherNames(sep string) []string {
+	if len(p) == 0 || !isPickSegment(p[len(p)-1]) {
+		return nil
+	}
+	last := p[len(p)-1]
+	names := strings.Split(last[1:len(last)-1], sep)
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names
+}
+
+// isPickSegment checks if segment is a brace-enclosed list of names.
+func isPickSegment(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
 // tagPathsParts represents multiple path segments in a smap tag.
 type tagPathsParts []tagPathParts
 
@@ -32,18 +106,76 @@ func (p tagPathsParts) String() string {
 	return strings.Join(paths, "|")
 }
 
+// TagSplitter defines the separators of the smap tag grammar. Empty fields
+// use the default separators.
+type TagSplitter struct {
+	Paths    string // Separates alternative paths; default "|"
+	Options  string // Separates paths from options and options from each other; default ","
+	Segments string // Separates the segments of a path; default "."
+}
+
+// defaultTagSplitter is the default smap tag grammar.
+var defaultTagSplitter = TagSplitter{
+	Paths:    "|",
+	Options:  ",",
+	Segments: ".",
+}
+
+// conflict describes why the separators of sp, with defaults applied, cannot
+// be told apart, or returns "" when they can: none may contain another, or the
+// quote and brace characters that group segments.
+func (sp TagSplitter) conflict() string {
+	seps := []struct{ name, sep string }{
+		{"paths", sp.Paths},
+		{"options", sp.Options},
+		{"segments", sp.Segments},
+	}
+	for i, a := range seps {
+		if strings.ContainsAny(a.sep, `"{}`) {
+			return fmt.Sprintf("%s separator %q contains a quote or brace", a.name, a.sep)
+		}
+		for _, b := range seps[i+1:] {
+			if strings.Contains(a.sep, b.sep) || strings.Contains(b.sep, a.sep) {
+				return fmt.Sprintf("%s separator %q overlaps %s separator %q", a.name, a.sep, b.name, b.sep)
+			}
+		}
+	}
+	return ""
+}
+
+// withDefaults returns a copy of sp with empty separators set to defaults.
+func (sp TagSplitter) withDefaults() TagSplitter {
+	if sp.Paths == "" {
+		sp.Paths = defaultTagSplitter.Paths
+	}
+	if sp.Options == "" {
+		sp.Options = defaultTagSplitter.Options
+	}
+	if sp.Segments == "" {
+		sp.Segments = defaultTagSplitter.Segments
+	}
+	return sp
+}
+
 // sTag represents a parsed smap tag with paths and options.
 type sTag struct {
 	pathsParts tagPathsParts
 	opts       []string
+	splitter   TagSplitter
 }
 
 // String recreates the original smap tag string.
 func (t *sTag) String() string {
+	sp := t.splitter.withDefaults()
+	paths := make([]string, len(t.pathsParts))
+	for i, pathParts := range t.pathsParts {
+		paths[i] = pathParts.Join(sp)
+	}
+	pathsStr := strings.Join(paths, sp.Paths)
 	if len(t.opts) == 0 {
-		return t.pathsParts.String()
+		return pathsStr
 	}
-	return t.pathsParts.String() + "," + strings.Join(t.opts, ",")
+	return pathsStr + sp.Options + strings.Join(t.opts, sp.Options)
 }
 
 // HasHydrate checks if the "hydrate" option is present.
@@ -56,6 +188,16 @@ func (t *sTag) HasHydrate() bool {
 	return false
 }
 
+// HasNoHydrate checks if the "nohydrate" option is present.
+func (t *sTag) HasNoHydrate() bool {
+	for _, opt := range t.opts {
+		if opt == "nohydrate" {
+			return true
+		}
+	}
+	return false
+}
+
 // HasSkipZero checks if the "skipzero" option is present.
 func (t *sTag) HasSkipZero() bool {
 	for _, opt := range t.opts {
@@ -66,30 +208,237 @@ func (t *sTag) HasSkipZero() bool {
 	return false
 }
 
+// HasNoZeroSkip checks if the "nozeroskip" option is present.
+func (t *sTag) HasNoZeroSkip() bool {
+	for _, opt := range t.opts {
+		if opt == "nozeroskip" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSkipNil checks if the "skipnil" option is present.
+func (t *sTag) HasSkipNil() bool {
+	for _, opt := range t.opts {
+		if opt == "skipnil" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasNilOnZero checks if the "nilonzero" option is present.
+func (t *sTag) HasNilOnZero() bool {
+	for _, opt := range t.opts {
+		if opt == "nilonzero" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOnErrorSkip checks if the "onerror" option is set to "skip". Any other
+// value, or no "onerror" option, means "fail".
+func (t *sTag) IsOnErrorSkip() bool {
+	v, _ := t.optValue("onerror")
+	return v == "skip"
+}
+
+// FallbackEnv reports the environment variable named by the "fallbackenv"
+// option and whether the option is present. A bare "fallbackenv" names the
+// last segment of the last path; "fallbackenv=NAME" names NAME.
+func (t *sTag) FallbackEnv() (string, bool) {
+	name, ok := t.optValue("fallbackenv")
+	if !ok {
+		return "", false
+	}
+	if name == "" && len(t.pathsParts) > 0 {
+		if last := t.pathsParts[len(t.pathsParts)-1]; len(last) > 0 {
+			name = last[len(last)-1]
+		}
+	}
+	return name, name != ""
+}
+
+// DurationUnit returns the value of the "unit" option (e.g. "ms"), or an empty
+// string if it is not set.
+func (t *sTag) DurationUnit() string {
+	v, _ := t.optValue("unit")
+	return v
+}
+
+// Setter reports the destination method named by the "setter" option (e.g.
+// "setter=SetPort") and whether the option names one.
+func (t *sTag) Setter() (string, bool) {
+	name, _ := t.optValue("setter")
+	return name, name != ""
+}
+
+// HasMerge checks if the "merge" option is present.
+func (t *sTag) HasMerge() bool {
+	for _, opt := range t.opts {
+		if opt == "merge" {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultField reports the destination field named by the "defaultfield"
+// option (e.g. "defaultfield=Name") and whether the option names one.
+func (t *sTag) DefaultField() (string, bool) {
+	name, _ := t.optValue("defaultfield")
+	return name, name != ""
+}
+
+// HasPOSIX checks if the "posix" option is present.
+func (t *sTag) HasPOSIX() bool {
+	for _, opt := range t.opts {
+		if opt == "posix" {
+			return true
+		}
+	}
+	return false
+}
+
+// StringTransform returns the string transform selected by the "trim" and
+// "lowercase" options (trimming first), or nil if neither is present.
+func (t *sTag) StringTransform() func(string) string {
+	var trim, lower bool
+	for _, opt := range t.opts {
+		switch opt {
+		case "trim":
+			trim = true
+		case "lowercase":
+			lower = true
+		}
+	}
+	if !trim && !lower {
+		return nil
+	}
+	return func(s string) string {
+		if trim {
+			s = strings.TrimSpace(s)
+		}
+		if lower {
+			s = strings.ToLower(s)
+		}
+		return s
+	}
+}
+
+// HasRune checks if the "rune" option is present.
+func (t *sTag) HasRune() bool {
+	for _, opt := range t.opts {
+		if opt == "rune" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllPaths checks if the "allpaths" option is present.
+func (t *sTag) HasAllPaths() bool {
+	for _, opt := range t.opts {
+		if opt == "allpaths" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasBase64 checks if the "base64" option is present, with or without a value
+// (e.g. "base64=url").
+func (t *sTag) HasBase64() bool {
+	_, ok := t.optValue("base64")
+	return ok
+}
+
+// IsBase64URL checks if the "base64" option is set to "url".
+func (t *sTag) IsBase64URL() bool {
+	v, _ := t.optValue("base64")
+	return v == "url"
+}
+
+// HasStringer checks if the "stringer" option is present.
+func (t *sTag) HasStringer() bool {
+	for _, opt := range t.opts {
+		if opt == "stringer" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasJSON checks if the "json" option is present.
+func (t *sTag) HasJSON() bool {
+	for _, opt := range t.opts {
+		if opt == "json" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasIntBool checks if the "intbool" option is present, with or without a
+// value (e.g. "intbool=strict").
+func (t *sTag) HasIntBool() bool {
+	_, ok := t.optValue("intbool")
+	return ok
+}
+
+// IsIntBoolStrict checks if the "intbool" option is set to "strict".
+func (t *sTag) IsIntBoolStrict() bool {
+	v, _ := t.optValue("intbool")
+	return v == "strict"
+}
+
+// optValue returns the value of the named option and whether it is present.
+// Options without a value (e.g. "hydrate") report an empty value.
+func (t *sTag) optValue(name string) (string, bool) {
+	for _, opt := range t.opts {
+		if opt == name {
+			return "", true
+		}
+		if strings.HasPrefix(opt, name+"=") {
+			return opt[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
 // IsEmpty checks if the tag has no paths.
 func (t *sTag) IsEmpty() bool {
 	return len(t.pathsParts) == 0
 }
 
-// newSTag constructs an sTag from a tag string.
-func newSTag(tag string) (*sTag, error) {
-	// Split into paths and options at the first comma
-	parts := strings.SplitN(tag, ",", 2)
+// newSTag constructs an sTag from a tag string using the grammar of sp.
+func newSTag(tag string, sp TagSplitter) (*sTag, error) {
+	sp = sp.withDefaults()
+	if detail := sp.conflict(); detail != "" {
+		return nil, NewTagError(ErrTagSplitterInvalid, tag, detail)
+	}
+
+	// Split into paths and options at the first option separator
+	parts := splitOutsideBraces(tag, sp.Options, 2)
 	pathsStr := strings.TrimSpace(parts[0])
 
-	// Parse paths (split by "|")
-	paths := strings.Split(pathsStr, "|")
+	// Parse paths (split by the paths separator)
+	paths := splitOutsideBraces(pathsStr, sp.Paths, -1)
 	var pathsParts tagPathsParts
 	for _, path := range paths {
 		if path == "" {
 			continue
 		}
-		segments := strings.Split(path, ".")
-		for _, segment := range segments {
+		segments := splitOutsideBraces(path, sp.Segments, -1)
+		for i, segment := range segments {
 			if segment == "" {
-				return nil, ErrTagInvalid // Empty segment (e.g., "Foo..Bar")
+				return nil, NewTagError(ErrTagInvalid, tag, emptySegmentDetail(i, len(segments), sp.Segments))
 			}
+			segments[i] = unquoteSegment(segment)
 		}
+		segments = splitAttachedPick(segments)
 		pp := tagPathParts(segments)
 		if pp.IsEmpty() { // Optional: already caught by segment check, but explicit
 			continue
@@ -103,11 +452,11 @@ func newSTag(tag string) (*sTag, error) {
 	// Parse options if present
 	var opts []string
 	if len(parts) > 1 {
-		opts = strings.Split(strings.TrimSpace(parts[1]), ",")
+		opts = strings.Split(strings.TrimSpace(parts[1]), sp.Options)
 		for i, opt := range opts {
 			opt = strings.TrimSpace(opt)
 			if opt == "" {
-				return nil, ErrTagInvalid // Empty option (e.g., "path,,hydrate")
+				return nil, NewTagError(ErrTagInvalid, tag, "empty option") // e.g., "path,,hydrate"
 			}
 			opts[i] = opt
 		}
@@ -116,5 +465,82 @@ func newSTag(tag string) (*sTag, error) {
 	return &sTag{
 		pathsParts: pathsParts,
 		opts:       opts,
+		splitter:   sp,
 	}, nil
 }
+
+// splitAttachedPick separates a pick segment attached to the final segment
+// without a segment separator (e.g. "EV{URL,Key}") into its own segment, as if
+// written "EV.{URL,Key}".
+func splitAttachedPick(segments []string) []string {
+	last := segments[len(segments)-1]
+	i := strings.Index(last, "{")
+	if i <= 0 || strings.HasPrefix(last, `"`) || !isPickSegment(last[i:]) {
+		return segments
+	}
+	return append(segments[:len(segments)-1], last[:i], last[i:])
+}
+
+// unquoteSegment strips the double quotes enclosing a segment, which allow a
+// map key to contain separators (e.g. `Data."a,b"` or `Data."a.b"`).
+func unquoteSegment(segment string) string {
+	if len(segment) >= 2 && segment[0] == '"' && segment[len(segment)-1] == '"' {
+		return segment[1 : len(segment)-1]
+	}
+	return segment
+}
+
+// quoteSegment reverses unquoteSegment for a segment other than a pick segment
+// containing any separator of sp, so that it survives sTag.String.
+func quoteSegment(segment string, sp TagSplitter) string {
+	if isPickSegment(segment) {
+		return segment
+	}
+	for _, sep := range []string{sp.Paths, sp.Options, sp.Segments} {
+		if strings.Contains(segment, sep) {
+			return `"` + segment + `"`
+		}
+	}
+	return segment
+}
+
+// emptySegmentDetail describes an empty path segment by its position, naming
+// the segment separator sep.
+func emptySegmentDetail(index, count int, sep string) string {
+	name := strconv.Quote(sep)
+	if sep == defaultTagSplitter.Segments {
+		name = "dot"
+	}
+	switch index {
+	case 0:
+		return "leading " + name + " in path" // e.g., ".Foo.Bar"
+	case count - 1:
+		return "trailing " + name + " in path" // e.g., "Foo.Bar."
+	default:
+		return "empty segment in path" // e.g., "Foo..Bar"
+	}
+}
+
+// splitOutsideBraces splits s around sep like strings.SplitN, except that
+// separators within braces (e.g. the commas of "{URL,Key}") or double quotes
+// (e.g. the comma of `Data."a,b"`) do not split.
+func splitOutsideBraces(s, sep string, n int) []string {
+	var parts []string
+	depth, start, quoted := 0, 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case quoted:
+		case s[i] == '{':
+			depth++
+		case s[i] == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep) && (n < 0 || len(parts) < n-1):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}